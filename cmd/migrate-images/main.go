@@ -0,0 +1,145 @@
+// Command migrate-images moves base64 images stored inline in detection_runs and
+// detection_results into blob storage, leaving only storage keys in the database.
+// It is safe to run more than once: rows that already hold keys are skipped.
+//
+//	go run ./cmd/migrate-images
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"yolo-server/db"
+	"yolo-server/storage"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️  Tidak menemukan file .env, lanjut pakai environment bawaan")
+	}
+
+	database := db.ConnectDB(
+		getEnv("POSTGRES_HOST", "localhost"),
+		getEnv("POSTGRES_USER", "user"),
+		getEnv("POSTGRES_PASSWORD", "password"),
+		getEnv("POSTGRES_DB", "yolo_db"),
+	)
+	defer database.Close()
+
+	ctx := context.Background()
+	store, err := storage.New(ctx, storage.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan penyimpanan gambar: %v", err)
+	}
+
+	runs, err := migrateTable(ctx, database, store, "detection_runs")
+	if err != nil {
+		log.Fatalf("❌ Migrasi detection_runs gagal: %v", err)
+	}
+	fmt.Printf("✅ detection_runs: %d baris dimigrasi\n", runs)
+
+	// Latest results normally point at a run that now holds keys; reuse those.
+	if _, err := database.Exec(`
+		UPDATE detection_results dr
+		SET original_image = r.original_image, annotated_image = r.annotated_image
+		FROM detection_runs r
+		WHERE r.id = dr.latest_run_id AND (dr.original_image LIKE 'data:%' OR dr.annotated_image LIKE 'data:%')
+	`); err != nil {
+		log.Fatalf("❌ Gagal menyalin key dari detection_runs: %v", err)
+	}
+
+	results, err := migrateTable(ctx, database, store, "detection_results")
+	if err != nil {
+		log.Fatalf("❌ Migrasi detection_results gagal: %v", err)
+	}
+	fmt.Printf("✅ detection_results: %d baris dimigrasi\n", results)
+
+	for _, table := range []string{"detection_runs", "detection_results"} {
+		if _, err := database.Exec(fmt.Sprintf(
+			"UPDATE %s SET comparison_result_json = comparison_result_json - 'originalImage' - 'annotatedImage' WHERE comparison_result_json ? 'originalImage' OR comparison_result_json ? 'annotatedImage'",
+			table,
+		)); err != nil {
+			log.Fatalf("❌ Gagal membersihkan gambar dari %s.comparison_result_json: %v", table, err)
+		}
+	}
+	fmt.Println("✅ Migrasi gambar selesai")
+}
+
+// migrateTable uploads every inline image of table and replaces it with its key.
+func migrateTable(ctx context.Context, database *sql.DB, store storage.Store, table string) (int, error) {
+	rows, err := database.Query(fmt.Sprintf(
+		"SELECT id, bom_code, original_image, annotated_image FROM %s WHERE original_image LIKE 'data:%%' OR annotated_image LIKE 'data:%%' ORDER BY id",
+		table,
+	))
+	if err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		id                  int
+		bomCode             string
+		original, annotated string
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.bomCode, &r.original, &r.annotated); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range pending {
+		originalKey, err := moveImage(ctx, store, fmt.Sprintf("migrated/%s/%d-original", table, r.id), r.original)
+		if err != nil {
+			return 0, fmt.Errorf("%s id %d: %w", table, r.id, err)
+		}
+		annotatedKey, err := moveImage(ctx, store, fmt.Sprintf("migrated/%s/%d-annotated", table, r.id), r.annotated)
+		if err != nil {
+			return 0, fmt.Errorf("%s id %d: %w", table, r.id, err)
+		}
+
+		if _, err := database.Exec(
+			fmt.Sprintf("UPDATE %s SET original_image = $1, annotated_image = $2 WHERE id = $3", table),
+			originalKey, annotatedKey, r.id,
+		); err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
+
+// moveImage uploads a data URL under keyPrefix. Values that are not data URLs are
+// already keys and are returned unchanged.
+func moveImage(ctx context.Context, store storage.Store, keyPrefix, value string) (string, error) {
+	if !strings.HasPrefix(value, "data:") {
+		return value, nil
+	}
+	data, contentType, err := storage.DecodeDataURL(value)
+	if err != nil {
+		return "", err
+	}
+	key := keyPrefix + storage.ExtensionFor(contentType)
+	if err := store.Put(ctx, key, data, contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,45 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+
+	"yolo-server/models"
+)
+
+// Request is a single image sent for detection. Model holds custom weights to
+// use instead of the predictor's default ones, uploaded as the .pt file ModelFile.
+// ModelKey identifies the weights, so a predictor that already has them need not
+// receive them again.
+type Request struct {
+	FileName  string
+	Image     []byte
+	Params    models.DetectionParams
+	Model     []byte
+	ModelFile string
+	ModelKey  string
+}
+
+// Result is what a detector saw in an image.
+type Result struct {
+	Summary        []models.DetectionSummary
+	AnnotatedImage string
+}
+
+// Detector counts the parts visible in an image.
+type Detector interface {
+	Detect(ctx context.Context, req Request) (*Result, error)
+}
+
+// New returns the detector selected by kind: "http" calls the Python predictor at
+// pythonApiUrl, "fake" replays scripted responses from fixtureDir.
+func New(kind, pythonApiUrl, fixtureDir string) (Detector, error) {
+	switch kind {
+	case "", "http":
+		return NewHTTPDetector(pythonApiUrl), nil
+	case "fake":
+		return NewFakeDetector(fixtureDir)
+	default:
+		return nil, fmt.Errorf("detector %q tidak dikenal (pilih http atau fake)", kind)
+	}
+}
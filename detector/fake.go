@@ -0,0 +1,70 @@
+package detector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yolo-server/models"
+)
+
+// FakeDetector replays scripted predictor responses from a fixture directory.
+// For an upload named "kit-a.jpg" it reads "kit-a.json", falling back to
+// "default.json". A fixture has the same shape as the predictor response and may
+// set "error" to script a failure. Without an annotated image the original image
+// is returned in its place.
+type FakeDetector struct {
+	Dir string
+}
+
+type fakeFixture struct {
+	models.PythonResponse
+	Error string `json:"error"`
+}
+
+func NewFakeDetector(dir string) (*FakeDetector, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("direktori fixture detector tidak bisa dibaca: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s bukan direktori", dir)
+	}
+	return &FakeDetector{Dir: dir}, nil
+}
+
+func (d *FakeDetector) Detect(ctx context.Context, r Request) (*Result, error) {
+	stem := strings.TrimSuffix(filepath.Base(r.FileName), filepath.Ext(r.FileName))
+
+	var data []byte
+	var err error
+	for _, name := range []string{stem + ".json", "default.json"} {
+		data, err = os.ReadFile(filepath.Join(d.Dir, name))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fixture untuk %q tidak ditemukan: %w", r.FileName, err)
+	}
+
+	var fixture fakeFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("fixture untuk %q tidak valid: %w", r.FileName, err)
+	}
+	if fixture.Error != "" {
+		return nil, errors.New(fixture.Error)
+	}
+
+	annotated := fixture.AnnotatedImage
+	if annotated == "" {
+		annotated = fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(r.Image), base64.StdEncoding.EncodeToString(r.Image))
+	}
+	return &Result{Summary: fixture.Summary, AnnotatedImage: annotated}, nil
+}
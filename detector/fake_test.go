@@ -0,0 +1,91 @@
+package detector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"yolo-server/models"
+)
+
+func writeFixture(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeDetector(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "kit-a.json", `{"summary": [{"class_name": "Bolt", "quantity": 4, "avg_confidence": 0.9}], "annotated_image": "data:image/png;base64,AA=="}`)
+	writeFixture(t, dir, "default.json", `{"summary": [{"class_name": "Nut", "quantity": 2, "avg_confidence": 0.8}]}`)
+	writeFixture(t, dir, "broken.json", `{"error": "CUDA out of memory"}`)
+
+	det, err := NewFakeDetector(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		fileName      string
+		wantSummary   []models.DetectionSummary
+		wantAnnotated string
+		wantErr       string
+	}{
+		{
+			name:          "fixture named after the upload",
+			fileName:      "kit-a.jpg",
+			wantSummary:   []models.DetectionSummary{{ClassName: "Bolt", Quantity: 4, AvgConfidence: 0.9}},
+			wantAnnotated: "data:image/png;base64,AA==",
+		},
+		{
+			name:          "default fixture echoes the image",
+			fileName:      "other.jpg",
+			wantSummary:   []models.DetectionSummary{{ClassName: "Nut", Quantity: 2, AvgConfidence: 0.8}},
+			wantAnnotated: "data:text/plain; charset=utf-8;base64,cGhvdG8=",
+		},
+		{
+			name:     "scripted failure",
+			fileName: "broken.jpg",
+			wantErr:  "CUDA out of memory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := det.Detect(context.Background(), Request{FileName: tt.fileName, Image: []byte("photo")})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Detect(%s) error = %v, want %q", tt.fileName, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Detect(%s): %v", tt.fileName, err)
+			}
+			if !reflect.DeepEqual(result.Summary, tt.wantSummary) {
+				t.Errorf("summary = %+v, want %+v", result.Summary, tt.wantSummary)
+			}
+			if result.AnnotatedImage != tt.wantAnnotated {
+				t.Errorf("annotated image = %q, want %q", result.AnnotatedImage, tt.wantAnnotated)
+			}
+		})
+	}
+}
+
+func TestFakeDetectorWithoutFixture(t *testing.T) {
+	det, err := NewFakeDetector(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := det.Detect(context.Background(), Request{FileName: "kit.jpg"}); err == nil {
+		t.Error("Detect without a fixture succeeded, want an error")
+	}
+	if _, err := NewFakeDetector(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("NewFakeDetector on a missing directory succeeded, want an error")
+	}
+}
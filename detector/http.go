@@ -0,0 +1,147 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"yolo-server/models"
+)
+
+// HTTPDetector sends images to the YOLO Flask service as multipart requests.
+// Custom weights with a ModelKey are uploaded once; later requests only name the
+// key, and the weights are sent again if the predictor no longer has them.
+type HTTPDetector struct {
+	URL    string
+	Client *http.Client
+
+	// uploaded holds the model keys the predictor has received.
+	uploaded sync.Map
+}
+
+func NewHTTPDetector(url string) *HTTPDetector {
+	return &HTTPDetector{
+		URL:    url,
+		Client: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+func (d *HTTPDetector) Detect(ctx context.Context, r Request) (*Result, error) {
+	if r.ModelKey != "" && len(r.Model) > 0 {
+		if _, ok := d.uploaded.Load(r.ModelKey); ok {
+			resp, err := d.post(ctx, r, false)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusConflict {
+				return decodeResponse(resp)
+			}
+			// The predictor restarted or dropped the weights from its cache.
+			resp.Body.Close()
+			d.uploaded.Delete(r.ModelKey)
+		}
+	}
+
+	resp, err := d.post(ctx, r, true)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeResponse(resp)
+	if err == nil && r.ModelKey != "" && len(r.Model) > 0 {
+		d.uploaded.Store(r.ModelKey, struct{}{})
+	}
+	return result, err
+}
+
+// post sends the image, with the weights when withModel is set.
+func (d *HTTPDetector) post(ctx context.Context, r Request, withModel bool) (*http.Response, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", r.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(r.Image); err != nil {
+		return nil, err
+	}
+	if !withModel {
+		r.Model = nil
+	}
+	if err := writeParams(writer, r); err != nil {
+		return nil, err
+	}
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("python API tidak merespon: %w", err)
+	}
+	return resp, nil
+}
+
+// decodeResponse reads the predictor response and closes it.
+func decodeResponse(resp *http.Response) (*Result, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return nil, fmt.Errorf("python API mengembalikan status %d: %s", resp.StatusCode, errResp.Error)
+	}
+
+	var result models.PythonResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("gagal decode response dari Python: %w", err)
+	}
+	return &Result{Summary: result.Summary, AnnotatedImage: result.AnnotatedImage}, nil
+}
+
+// writeParams adds the detection parameters as the form fields the predictor
+// reads; unset ones are left out so the predictor's defaults apply.
+func writeParams(writer *multipart.Writer, r Request) error {
+	if r.Params.Conf != nil {
+		if err := writer.WriteField("conf", strconv.FormatFloat(*r.Params.Conf, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+	if r.Params.IoU != nil {
+		if err := writer.WriteField("iou", strconv.FormatFloat(*r.Params.IoU, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+	if r.Params.AgnosticNMS != nil {
+		if err := writer.WriteField("agnostic_nms", strconv.FormatBool(*r.Params.AgnosticNMS)); err != nil {
+			return err
+		}
+	}
+	if r.ModelKey != "" {
+		if err := writer.WriteField("model_key", r.ModelKey); err != nil {
+			return err
+		}
+	}
+	if len(r.Model) > 0 {
+		part, err := writer.CreateFormFile("model", r.ModelFile)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(r.Model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
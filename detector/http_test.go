@@ -0,0 +1,64 @@
+package detector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestHTTPDetectorSendsWeightsOnce checks that custom weights with a key are
+// uploaded once, and again after the predictor has lost them.
+func TestHTTPDetectorSendsWeightsOnce(t *testing.T) {
+	var mu sync.Mutex
+	cached := make(map[string]bool)
+	uploads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		key := r.FormValue("model_key")
+		if _, ok := r.MultipartForm.File["model"]; ok {
+			uploads++
+			cached[key] = true
+		} else if !cached[key] {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": "unknown model"}`))
+			return
+		}
+		w.Write([]byte(`{"summary": [{"class_name": "Bolt", "quantity": 1, "avg_confidence": 0.9}]}`))
+	}))
+	defer server.Close()
+
+	det := NewHTTPDetector(server.URL)
+	req := Request{FileName: "kit.jpg", Image: []byte("photo"), Model: []byte("weights"), ModelFile: "kit.pt", ModelKey: "abc"}
+	detect := func() {
+		t.Helper()
+		result, err := det.Detect(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Detect: %v", err)
+		}
+		if len(result.Summary) != 1 {
+			t.Fatalf("summary = %+v, want one class", result.Summary)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		detect()
+	}
+	if uploads != 1 {
+		t.Errorf("weights uploaded %d times for three images, want 1", uploads)
+	}
+
+	mu.Lock()
+	delete(cached, "abc")
+	mu.Unlock()
+	detect()
+	if uploads != 2 {
+		t.Errorf("weights uploaded %d times after the predictor lost them, want 2", uploads)
+	}
+}
@@ -0,0 +1,170 @@
+package alias
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const aliasColumns = "id, COALESCE(part_reference, ''), COALESCE(part_name, ''), class_name, loose_match, created_at, updated_at"
+
+func scanAlias(row interface{ Scan(...any) error }, a *models.PartClassAlias) error {
+	return row.Scan(&a.ID, &a.PartReference, &a.PartName, &a.ClassName, &a.LooseMatch, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func validateAlias(a models.PartClassAlias) string {
+	if strings.TrimSpace(a.ClassName) == "" {
+		return "className is required"
+	}
+	if strings.TrimSpace(a.PartReference) == "" && strings.TrimSpace(a.PartName) == "" {
+		return "partReference or partName is required"
+	}
+	return ""
+}
+
+func GetAliases(c *gin.Context, db *sql.DB) {
+	query := "SELECT " + aliasColumns + " FROM part_class_aliases WHERE ($1 = '' OR part_reference = $1) AND ($2 = '' OR part_name = $2) ORDER BY part_reference, part_name, class_name"
+	rows, err := db.Query(query, c.Query("partReference"), c.Query("partName"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch aliases"})
+		return
+	}
+	defer rows.Close()
+
+	aliases := []models.PartClassAlias{}
+	for rows.Next() {
+		var a models.PartClassAlias
+		if err := scanAlias(rows, &a); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan alias"})
+			return
+		}
+		aliases = append(aliases, a)
+	}
+	c.JSON(http.StatusOK, aliases)
+}
+
+func CreateAlias(c *gin.Context, db *sql.DB) {
+	var a models.PartClassAlias
+	if err := c.ShouldBindJSON(&a); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if msg := validateAlias(a); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := `
+		INSERT INTO part_class_aliases (part_reference, part_name, class_name, loose_match)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), $3, $4)
+		RETURNING ` + aliasColumns
+	row := db.QueryRow(query, a.PartReference, a.PartName, a.ClassName, a.LooseMatch)
+	if err := scanAlias(row, &a); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save alias: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, a)
+}
+
+func UpdateAlias(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alias ID"})
+		return
+	}
+
+	var patch struct {
+		PartReference *string `json:"partReference"`
+		PartName      *string `json:"partName"`
+		ClassName     *string `json:"className"`
+		LooseMatch    *bool   `json:"looseMatch"`
+	}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var a models.PartClassAlias
+	err = scanAlias(db.QueryRow("SELECT "+aliasColumns+" FROM part_class_aliases WHERE id = $1", id), &a)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alias not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alias"})
+		return
+	}
+
+	if patch.PartReference != nil {
+		a.PartReference = *patch.PartReference
+	}
+	if patch.PartName != nil {
+		a.PartName = *patch.PartName
+	}
+	if patch.ClassName != nil {
+		a.ClassName = *patch.ClassName
+	}
+	if patch.LooseMatch != nil {
+		a.LooseMatch = *patch.LooseMatch
+	}
+	if msg := validateAlias(a); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := `
+		UPDATE part_class_aliases
+		SET part_reference = NULLIF($1, ''), part_name = NULLIF($2, ''), class_name = $3, loose_match = $4
+		WHERE id = $5
+		RETURNING ` + aliasColumns
+	row := db.QueryRow(query, a.PartReference, a.PartName, a.ClassName, a.LooseMatch, id)
+	if err := scanAlias(row, &a); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alias: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func DeleteAlias(c *gin.Context, db *sql.DB) {
+	result, err := db.Exec("DELETE FROM part_class_aliases WHERE id = $1", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alias"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check affected rows"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alias not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alias deleted successfully"})
+}
+
+// LoadAll returns every alias; the comparison resolves BOM parts against them.
+func LoadAll(db *sql.DB) ([]models.PartClassAlias, error) {
+	rows, err := db.Query("SELECT " + aliasColumns + " FROM part_class_aliases ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []models.PartClassAlias
+	for rows.Next() {
+		var a models.PartClassAlias
+		if err := scanAlias(rows, &a); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
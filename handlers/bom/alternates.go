@@ -0,0 +1,64 @@
+package bom
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"yolo-server/models"
+)
+
+// alternatesColumn decodes the boms.alternates JSON column into a line.
+type alternatesColumn struct {
+	dst *[]models.BOMAlternate
+}
+
+func (a alternatesColumn) Scan(src any) error {
+	*a.dst = nil
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("tipe kolom alternates tidak dikenal: %T", src)
+	}
+	if err := json.Unmarshal(data, a.dst); err != nil {
+		return err
+	}
+	if len(*a.dst) == 0 {
+		*a.dst = nil
+	}
+	return nil
+}
+
+// alternatesValue encodes alternates for the boms.alternates column.
+func alternatesValue(alternates []models.BOMAlternate) []byte {
+	if len(alternates) == 0 {
+		return []byte("[]")
+	}
+	data, _ := json.Marshal(alternates)
+	return data
+}
+
+// validateAlternates checks that every alternate names a part, differs from the
+// part of the line and is listed once.
+func validateAlternates(entry models.BOMEntry) error {
+	seen := make(map[string]bool)
+	for _, alt := range entry.Alternates {
+		if alt.PartReference == "" && alt.PartName == "" {
+			return fmt.Errorf("alternatif part '%s' harus memiliki PartReference atau PartName", entry.PartName)
+		}
+		key := lineKey(models.BOMEntry{PartReference: alt.PartReference, PartName: alt.PartName})
+		if key == lineKey(entry) {
+			return fmt.Errorf("part '%s' tidak bisa menjadi alternatif dirinya sendiri", entry.PartName)
+		}
+		if seen[key] {
+			return fmt.Errorf("alternatif '%s%s' muncul lebih dari sekali pada part '%s'", alt.PartReference, alt.PartName, entry.PartName)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package bom
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"yolo-server/handlers/part"
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// findLine returns the index of the line with the given part reference, or with
+// the given part name when reference is empty, or -1.
+func findLine(lines []models.BOMEntry, reference, name string) int {
+	for i, line := range lines {
+		if reference != "" && line.PartReference == reference {
+			return i
+		}
+		if reference == "" && line.PartName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// scaleQuantity multiplies a quantity and rejects results that are not whole
+// positive numbers instead of rounding them silently.
+func scaleQuantity(quantity int, multiplier float64) (int, bool) {
+	scaled := float64(quantity) * multiplier
+	rounded := math.Round(scaled)
+	if math.Abs(scaled-rounded) > 1e-9 || rounded < 1 {
+		return 0, false
+	}
+	return int(rounded), true
+}
+
+// cloneLines builds the lines of the target BOM from the source lines.
+func cloneLines(source []models.BOMEntry, req models.BOMCloneRequest, catalog *part.Catalog) ([]models.BOMEntry, error) {
+	removed := make([]bool, len(source))
+	for _, name := range req.Removals {
+		i := findLine(source, name, "")
+		if i < 0 {
+			i = findLine(source, "", name)
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("baris '%s' yang akan dihapus tidak ditemukan", name)
+		}
+		removed[i] = true
+	}
+
+	lines := make([]models.BOMEntry, len(source))
+	for i, line := range source {
+		if !removed[i] {
+			quantity, ok := scaleQuantity(line.Quantity, req.Multiplier)
+			if !ok {
+				return nil, fmt.Errorf("quantity part '%s' (%d × %g) bukan bilangan bulat positif", line.PartName, line.Quantity, req.Multiplier)
+			}
+			line.ID = 0
+			line.BomCode = req.TargetBomCode
+			line.Quantity = quantity
+		}
+		lines[i] = line
+	}
+
+	var added []models.BOMEntry
+	for _, o := range req.Overrides {
+		if o.Quantity < 0 {
+			return nil, fmt.Errorf("quantity part '%s' tidak boleh negatif", o.PartName)
+		}
+		i := findLine(lines, o.PartReference, o.PartName)
+		if i < 0 {
+			o.ID = 0
+			o.BomCode = req.TargetBomCode
+			catalog.Fill(&o)
+			if err := validateBOMEntry(o); err != nil {
+				return nil, fmt.Errorf("baris baru '%s%s': %v", o.PartReference, o.PartName, err)
+			}
+			added = append(added, o)
+			continue
+		}
+		if removed[i] {
+			return nil, fmt.Errorf("baris '%s' tidak bisa diubah dan dihapus sekaligus", lines[i].PartName)
+		}
+		line := &lines[i]
+		if o.PartName != "" {
+			line.PartName = o.PartName
+		}
+		if o.PartDescription != "" {
+			line.PartDescription = o.PartDescription
+		}
+		if o.Quantity > 0 {
+			line.Quantity = o.Quantity
+		}
+		if o.ComponentBomCode != "" {
+			line.ComponentBomCode = o.ComponentBomCode
+		}
+		if o.Alternates != nil {
+			line.Alternates = o.Alternates
+			if err := validateAlternates(*line); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	entries := []models.BOMEntry{}
+	seen := make(map[string]bool)
+	for i, line := range append(lines, added...) {
+		if i < len(removed) && removed[i] {
+			continue
+		}
+		key := lineKey(line)
+		if seen[key] {
+			return nil, fmt.Errorf("part '%s' muncul lebih dari sekali di BOM %s", line.PartName, line.BomCode)
+		}
+		seen[key] = true
+		entries = append(entries, line)
+	}
+	return entries, nil
+}
+
+// CloneBOM copies a BOM, with its header metadata, to a new BOM code in one
+// transaction and returns the created lines.
+func CloneBOM(c *gin.Context, db *sql.DB) {
+	sourceCode := c.Param("id")
+
+	var req models.BOMCloneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+	req.TargetBomCode = strings.TrimSpace(req.TargetBomCode)
+	if req.TargetBomCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field TargetBomCode wajib diisi"})
+		return
+	}
+	if req.TargetBomCode == sourceCode {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "TargetBomCode harus berbeda dari BOM sumber"})
+		return
+	}
+	if req.Multiplier == 0 {
+		req.Multiplier = 1
+	}
+	if req.Multiplier < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multiplier harus > 0"})
+		return
+	}
+
+	exists, err := Exists(db, sourceCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa BOM"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+
+	source, err := CurrentLines(db, sourceCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+	catalog, err := part.LoadCatalog(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil katalog part"})
+		return
+	}
+	entries, err := cloneLines(source, req, catalog)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO bom_headers (bom_code, name, product_code, customer, production_line, owner, description, tags)
+		SELECT $1, name, product_code, customer, production_line, owner, description, tags
+		FROM bom_headers WHERE bom_code = $2
+	`, req.TargetBomCode, sourceCode)
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "BOM " + req.TargetBomCode + " sudah ada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat header BOM: " + err.Error()})
+		return
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		err := tx.QueryRow(`
+			INSERT INTO boms (bom_code, part_reference, part_name, part_description, quantity, component_bom_code, alternates)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+			RETURNING id
+		`, entry.BomCode, entry.PartReference, entry.PartName, entry.PartDescription, entry.Quantity, entry.ComponentBomCode, alternatesValue(entry.Alternates)).Scan(&entry.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan entri: " + err.Error()})
+			return
+		}
+	}
+
+	if err := validateComponents(tx, entries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan (commit) transaksi"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entries)
+}
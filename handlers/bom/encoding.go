@@ -0,0 +1,76 @@
+package bom
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	encodingUTF8        = "utf-8"
+	encodingWindows1252 = "windows-1252"
+)
+
+// csvDelimiters are the separators sniffDelimiter chooses from.
+const csvDelimiters = ",;\t|"
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// decodeCSV converts an uploaded file to UTF-8 and reports the encoding it was
+// read as. Without an explicit encoding, files that are not valid UTF-8 are
+// taken to be Windows-1252, which is what Excel writes on Windows machines.
+func decodeCSV(data []byte, encoding string) ([]byte, string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	switch encoding {
+	case encodingUTF8:
+		if !utf8.Valid(data) {
+			return nil, "", errors.New("file bukan UTF-8 yang valid")
+		}
+		return data, encodingUTF8, nil
+	case "":
+		if utf8.Valid(data) {
+			return data, encodingUTF8, nil
+		}
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return decoded, encodingWindows1252, nil
+}
+
+// sniffDelimiter picks the separator that occurs most often, outside quotes, in
+// the header line. Indonesian-locale Excel writes semicolons, so a plain comma
+// default breaks those files. Ties fall back to a comma.
+func sniffDelimiter(data []byte) string {
+	counts := make(map[rune]int)
+	inQuotes := false
+	for _, r := range string(data) {
+		if r == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if inQuotes {
+			continue
+		}
+		if r == '\n' {
+			break
+		}
+		if strings.ContainsRune(csvDelimiters, r) {
+			counts[r]++
+		}
+	}
+
+	best := ','
+	for _, r := range csvDelimiters {
+		if counts[r] > counts[best] {
+			best = r
+		}
+	}
+	return string(best)
+}
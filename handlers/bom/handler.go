@@ -3,155 +3,243 @@ package bom
 import (
 	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
-	"io"      
-	"log"   
 	"net/http"
 	"strconv"
 	"time"
 
+	"yolo-server/handlers/part"
 	"yolo-server/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
-func GetBOMs(c *gin.Context, db *sql.DB) {
-	query := `
-		SELECT
-			b.id, b.bom_code, b.part_reference, b.part_name, b.part_description, b.quantity,
-			CASE WHEN dr.bom_code IS NOT NULL THEN TRUE ELSE FALSE END AS has_detection_result,
-			COALESCE(ai.is_finalized, FALSE) AS is_finalized
-		FROM boms b
-		LEFT JOIN detection_results dr ON b.bom_code = dr.bom_code
-		LEFT JOIN (
-			-- Check if there are ANY 'SELESAI' items for a bom_code to consider it finalized (adjust logic if needed)
-			-- Or maybe check if ALL items are 'SELESAI'? This depends on your definition.
-			-- Simpler check: If ANY action item exists, maybe it's finalized? Let's check detection_results instead for simplicity now.
-			-- Let's assume finalized status is stored or inferred differently.
-			-- For now, let's just use a placeholder for is_finalized or derive it simply.
-			-- A common approach is to add an is_finalized column to detection_results table itself.
-			-- Let's query if ANY related action item exists as a proxy for finalized (can be refined).
-			SELECT DISTINCT bom_code, TRUE as is_finalized FROM actionable_items
-			-- Or a better approach: add is_finalized BOOLEAN to detection_results table
-			-- SELECT bom_code, is_finalized FROM detection_results
-		) ai ON b.bom_code = ai.bom_code
-		ORDER BY b.bom_code, b.part_name
-	`
-	rows, err := db.Query(query)
+func validateBOMEntry(entry models.BOMEntry) error {
+	if entry.BomCode == "" || entry.PartName == "" || entry.Quantity <= 0 {
+		return errors.New("Field BomCode, PartName, dan Quantity (harus > 0) wajib diisi")
+	}
+	return validateAlternates(entry)
+}
+
+// IsFinalized reports whether the detection result of a BOM has been finalized.
+func IsFinalized(db *sql.DB, bomCode string) (bool, error) {
+	var finalized bool
+	err := db.QueryRow("SELECT COALESCE(is_finalized, FALSE) FROM detection_results WHERE bom_code = $1", bomCode).Scan(&finalized)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return finalized, err
+}
+
+// rejectIfFinalized writes a 409 response and returns true when one of the BOM codes
+// is finalized and the request did not pass force=true.
+func rejectIfFinalized(c *gin.Context, db *sql.DB, bomCodes ...string) bool {
+	if c.Query("force") == "true" {
+		return false
+	}
+	for _, code := range bomCodes {
+		finalized, err := IsFinalized(db, code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa status finalisasi BOM"})
+			return true
+		}
+		if finalized {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("BOM %s sudah difinalisasi, tambahkan force=true untuk tetap mengubah", code),
+			})
+			return true
+		}
+	}
+	return false
+}
 
+// isUniqueViolation reports whether err comes from the (bom_code, part_reference)
+// unique index or another unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func getBOMEntryByID(db *sql.DB, id int) (models.BOMEntry, error) {
+	var entry models.BOMEntry
+	var desc sql.NullString
+	err := db.QueryRow(
+		"SELECT id, bom_code, part_reference, part_name, part_description, quantity, COALESCE(component_bom_code, ''), alternates FROM boms WHERE id = $1", id,
+	).Scan(&entry.ID, &entry.BomCode, &entry.PartReference, &entry.PartName, &desc, &entry.Quantity, &entry.ComponentBomCode, alternatesColumn{&entry.Alternates})
+	entry.PartDescription = desc.String
+	return entry, err
+}
+
+func GetBOMEntry(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	entry, err := getBOMEntryByID(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entri BOM tidak ditemukan"})
+		return
+	}
 	if err != nil {
-		log.Printf("Error querying BOMs with status: %v", err) 
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
 		return
 	}
-	defer rows.Close()
 
-	bomMap := make(map[string][]models.BOMEntryWithStatus)
-	bomStatusMap := make(map[string]struct{ HasResult bool; IsFinalized bool })
+	c.JSON(http.StatusOK, entry)
+}
 
-	for rows.Next() {
-		var bom models.BOMEntryWithStatus
-		var hasResult, isFinalized sql.NullBool 
-		if err := rows.Scan(
-			&bom.ID, &bom.BomCode, &bom.PartReference, &bom.PartName,
-			&bom.PartDescription, &bom.Quantity,
-			&hasResult, &isFinalized, 
-		); err != nil {
-			log.Printf("Error scanning BOM row: %v", err) 
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai baris data BOM"})
-			return
-		}
-		
-		bom.HasDetectionResult = hasResult.Valid && hasResult.Bool
-		bom.IsFinalized = isFinalized.Valid && isFinalized.Bool
-
-		if _, exists := bomStatusMap[bom.BomCode]; !exists {
-			bomStatusMap[bom.BomCode] = struct{ HasResult bool; IsFinalized bool }{
-				HasResult: bom.HasDetectionResult,
-				IsFinalized: bom.IsFinalized,
-			}
-		}
+func UpdateBOMEntry(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
 
-		bomMap[bom.BomCode] = append(bomMap[bom.BomCode], bom)
+	var patch models.BOMEntryPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
 	}
 
-	var finalBoms []models.BOMEntryWithStatus
-	bomCodes := []string{}
-	for code := range bomMap {
-		bomCodes = append(bomCodes, code)
+	entry, err := getBOMEntryByID(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entri BOM tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
 	}
 
-	for _, code := range bomCodes { 
-		entries := bomMap[code]
-		status := bomStatusMap[code]
-		for i := range entries {
-			entries[i].HasDetectionResult = status.HasResult
-			entries[i].IsFinalized = status.IsFinalized
-			finalBoms = append(finalBoms, entries[i])
-		}
+	originalCode := entry.BomCode
+	if patch.BomCode != nil {
+		entry.BomCode = *patch.BomCode
+	}
+	if patch.PartReference != nil {
+		entry.PartReference = *patch.PartReference
+	}
+	if patch.PartName != nil {
+		entry.PartName = *patch.PartName
+	}
+	if patch.PartDescription != nil {
+		entry.PartDescription = *patch.PartDescription
+	}
+	if patch.Quantity != nil {
+		entry.Quantity = *patch.Quantity
+	}
+	if patch.ComponentBomCode != nil {
+		entry.ComponentBomCode = *patch.ComponentBomCode
+	}
+	if patch.Alternates != nil {
+		entry.Alternates = *patch.Alternates
 	}
 
+	if err := validateBOMEntry(entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, finalBoms) 
-}
+	if rejectIfFinalized(c, db, originalCode, entry.BomCode) {
+		return
+	}
 
-func AddBOMEntry(c *gin.Context, db *sql.DB) {
-	var entry models.BOMEntry
-	if err := c.ShouldBindJSON(&entry); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
 		return
 	}
+	defer tx.Rollback()
 
-	if entry.BomCode == "" || entry.PartName == "" || entry.Quantity <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Field BomCode, PartName, dan Quantity (harus > 0) wajib diisi"})
+	if err := ensureHeader(tx, entry.BomCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat header BOM"})
 		return
 	}
 
 	query := `
-        INSERT INTO boms (bom_code, part_reference, part_name, part_description, quantity)
-        VALUES ($1, $2, $3, $4, $5)
-        RETURNING id
+        UPDATE boms
+        SET bom_code = $1, part_reference = $2, part_name = $3, part_description = $4, quantity = $5,
+            component_bom_code = NULLIF($6, ''), alternates = $7
+        WHERE id = $8
     `
-	err := db.QueryRow(
-		query,
-		entry.BomCode,
-		entry.PartReference,
-		entry.PartName,
-		entry.PartDescription,
-		entry.Quantity,
-	).Scan(&entry.ID)
+	if _, err := tx.Exec(query, entry.BomCode, entry.PartReference, entry.PartName, entry.PartDescription, entry.Quantity, entry.ComponentBomCode, alternatesValue(entry.Alternates), id); err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Part reference %s sudah ada di BOM %s", entry.PartReference, entry.BomCode)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui entri BOM: " + err.Error()})
+		return
+	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan ke database: " + err.Error()})
+	if err := validateComponents(tx, []models.BOMEntry{entry}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, entry)
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal commit transaksi"})
+		return
+	}
+
+	warnIfReleased(c, db, entry.BomCode)
+	c.JSON(http.StatusOK, entry)
 }
 
-func ImportBOMs(c *gin.Context, db *sql.DB) {
-	fileHeader, err := c.FormFile("file")
+func DeleteBOMEntry(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak ditemukan"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
 		return
 	}
 
-	file, err := fileHeader.Open()
+	entry, err := getBOMEntryByID(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entri BOM tidak ditemukan"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka file"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
 		return
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	
-	_, err = reader.Read()
-	if err == io.EOF {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File CSV kosong"})
+	if rejectIfFinalized(c, db, entry.BomCode) {
 		return
 	}
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membaca header CSV: " + err.Error()})
+
+	// Without its last line a sub-assembly could no longer be exploded into its parents.
+	var lineCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM boms WHERE bom_code = $1", entry.BomCode).Scan(&lineCount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+	if lineCount == 1 && rejectIfComponent(c, db, entry.BomCode) {
+		return
+	}
+
+	if _, err := db.Exec("DELETE FROM boms WHERE id = $1", id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus entri BOM: " + err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "Entri BOM berhasil dihapus"}
+	if warning := warnIfReleased(c, db, entry.BomCode); warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteBOMByCode removes a BOM: its lines and its header, together with the
+// settings that belong to the header. Past detection runs are kept.
+func DeleteBOMByCode(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("bomCode")
+
+	if rejectIfFinalized(c, db, bomCode) {
+		return
+	}
+	if rejectIfComponent(c, db, bomCode) {
 		return
 	}
 
@@ -160,56 +248,108 @@ func ImportBOMs(c *gin.Context, db *sql.DB) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
 		return
 	}
-	defer tx.Rollback() 
+	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-        INSERT INTO boms (bom_code, part_reference, part_name, part_description, quantity)
-        VALUES ($1, $2, $3, $4, $5)
-    `)
+	result, err := tx.Exec("DELETE FROM boms WHERE bom_code = $1", bomCode)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyiapkan statement database"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus BOM: " + err.Error()})
+		return
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa jumlah baris yang dihapus"})
 		return
 	}
-	defer stmt.Close()
 
-	rowCount := 0
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break 
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca baris CSV: " + err.Error()})
-			return
-		}
+	header, err := tx.Exec("DELETE FROM bom_headers WHERE bom_code = $1", bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus header BOM: " + err.Error()})
+		return
+	}
+	headerDeleted, err := header.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa jumlah baris yang dihapus"})
+		return
+	}
+	if rowsAffected == 0 && headerDeleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
 
-		if len(record) < 5 {
-			log.Printf("Baris tidak valid, dilewati: %v", record)
-			continue 
-		}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal commit transaksi"})
+		return
+	}
 
-		quantity, err := strconv.Atoi(record[4])
-		if err != nil || quantity <= 0 {
-			log.Printf("Quantity tidak valid, dilewati: %s", record[4])
-			continue 
-		}
-		
-		bomCode := record[0]
-		partRef := record[1]
-		partName := record[2]
-		partDesc := record[3]
-
-		if bomCode == "" || partName == "" {
-			log.Printf("Data tidak valid, dilewati: %s, %s", bomCode, partName)
-			continue 
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("BOM %s berhasil dihapus (%d baris)", bomCode, rowsAffected),
+	})
+}
 
-		_, err = stmt.Exec(bomCode, partRef, partName, partDesc, quantity)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan baris: " + err.Error()})
+func AddBOMEntry(c *gin.Context, db *sql.DB) {
+	var entry models.BOMEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+
+	catalog, err := part.LoadCatalog(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil katalog part"})
+		return
+	}
+	// A line may name only the catalog part number; name and description follow.
+	catalog.Fill(&entry)
+
+	if err := validateBOMEntry(entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rejectIfFinalized(c, db, entry.BomCode) {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := ensureHeader(tx, entry.BomCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat header BOM"})
+		return
+	}
+
+	query := `
+        INSERT INTO boms (bom_code, part_reference, part_name, part_description, quantity, component_bom_code, alternates)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+        RETURNING id
+    `
+	err = tx.QueryRow(
+		query,
+		entry.BomCode,
+		entry.PartReference,
+		entry.PartName,
+		entry.PartDescription,
+		entry.Quantity,
+		entry.ComponentBomCode,
+		alternatesValue(entry.Alternates),
+	).Scan(&entry.ID)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Part reference %s sudah ada di BOM %s", entry.PartReference, entry.BomCode)})
 			return
 		}
-		rowCount++
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan ke database: " + err.Error()})
+		return
+	}
+
+	if err := validateComponents(tx, []models.BOMEntry{entry}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -217,46 +357,63 @@ func ImportBOMs(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{
-		"message": fmt.Sprintf("Import sukses! %d baris berhasil ditambahkan.", rowCount),
-	})
+	warnIfReleased(c, db, entry.BomCode)
+	c.JSON(http.StatusCreated, entry)
 }
 
+// ExportBOMs writes every BOM line as CSV, or as an Excel workbook with ?format=xlsx.
 func ExportBOMs(c *gin.Context, db *sql.DB) {
-	rows, err := db.Query("SELECT bom_code, part_reference, part_name, part_description, quantity FROM boms ORDER BY bom_code, part_name")
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format export tidak valid: " + format + " (gunakan csv atau xlsx)"})
+		return
+	}
+
+	rows, err := db.Query("SELECT bom_code, part_reference, part_name, COALESCE(part_description, ''), quantity, COALESCE(component_bom_code, '') FROM boms ORDER BY bom_code, part_name")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
 		return
 	}
 	defer rows.Close()
 
-	fileName := fmt.Sprintf("boms_export_%s.csv", time.Now().Format("20060102_150405"))
+	var entries []models.BOMEntry
+	for rows.Next() {
+		var bom models.BOMEntry
+		if err := rows.Scan(&bom.BomCode, &bom.PartReference, &bom.PartName, &bom.PartDescription, &bom.Quantity, &bom.ComponentBomCode); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai baris data BOM"})
+			return
+		}
+		entries = append(entries, bom)
+	}
 
-	c.Header("Content-Type", "text/csv")
+	fileName := fmt.Sprintf("boms_export_%s.%s", time.Now().Format("20060102_150405"), format)
 	c.Header("Content-Disposition", "attachment; filename="+fileName)
 
+	if format == "xlsx" {
+		c.Header("Content-Type", xlsxContentType)
+		if err := writeXLSX(c.Writer, entries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menulis file Excel"})
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
 	writer := csv.NewWriter(c.Writer)
 
-	headers := []string{"bom_code", "part_reference", "part_name", "part_description", "quantity"}
-	if err := writer.Write(headers); err != nil {
+	if err := writer.Write(importColumns); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menulis header CSV"})
 		return
 	}
 
-	for rows.Next() {
-		var bom models.BOMEntry
+	for _, bom := range entries {
 		var record []string
 
-		if err := rows.Scan(&bom.BomCode, &bom.PartReference, &bom.PartName, &bom.PartDescription, &bom.Quantity); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai baris data BOM"})
-			return
-		}
-
 		record = append(record, bom.BomCode)
 		record = append(record, bom.PartReference)
 		record = append(record, bom.PartName)
 		record = append(record, bom.PartDescription)
 		record = append(record, fmt.Sprintf("%d", bom.Quantity))
+		record = append(record, bom.ComponentBomCode)
 
 		if err := writer.Write(record); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menulis baris CSV"})
@@ -271,7 +428,15 @@ func ExportBOMs(c *gin.Context, db *sql.DB) {
 	}
 }
 
+// AddBOMBatch writes a list of entries in one transaction. ?mode= works as for
+// ImportBOMs, but any invalid entry rejects the whole batch.
 func AddBOMBatch(c *gin.Context, db *sql.DB) {
+	mode, err := parseImportMode(c.Query("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var entries []models.BOMEntry
 	if err := c.ShouldBindJSON(&entries); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
@@ -283,42 +448,57 @@ func AddBOMBatch(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	tx, err := db.Begin()
+	catalog, err := part.LoadCatalog(db)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil katalog part"})
 		return
 	}
-	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-        INSERT INTO boms (bom_code, part_reference, part_name, part_description, quantity)
-        VALUES ($1, $2, $3, $4, $5)
-    `)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyiapkan statement database"})
-		return
-	}
-	defer stmt.Close()
-
-	for _, entry := range entries {
-		if entry.BomCode == "" || entry.PartName == "" || entry.Quantity <= 0 {
+	bomCodes := []string{}
+	seenCodes := make(map[string]bool)
+	seenLines := make(map[string]bool)
+	for i := range entries {
+		catalog.Fill(&entries[i])
+		entry := entries[i]
+		if err := validateBOMEntry(entry); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": fmt.Sprintf("Entri tidak valid: Part '%s' harus memiliki BomCode, PartName, dan Quantity > 0", entry.PartName),
 			})
 			return
 		}
-
-		_, err := stmt.Exec(
-			entry.BomCode,
-			entry.PartReference,
-			entry.PartName,
-			entry.PartDescription,
-			entry.Quantity,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan entri: " + err.Error()})
+		key := entry.BomCode + "\x00" + lineKey(entry)
+		if seenLines[key] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Entri duplikat: Part '%s' muncul lebih dari sekali di BOM %s", entry.PartName, entry.BomCode),
+			})
 			return
 		}
+		seenLines[key] = true
+		if !seenCodes[entry.BomCode] {
+			seenCodes[entry.BomCode] = true
+			bomCodes = append(bomCodes, entry.BomCode)
+		}
+	}
+	if rejectIfFinalized(c, db, bomCodes...) {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, summary, err := applyLines(tx, entries, mode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan entri: " + err.Error()})
+		return
+	}
+
+	if err := validateComponents(tx, entries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -326,7 +506,12 @@ func AddBOMBatch(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": fmt.Sprintf("Berhasil menambahkan %d entri BOM", len(entries)),
-	})
-}
\ No newline at end of file
+	response := gin.H{
+		"message": fmt.Sprintf("Berhasil memproses %d entri BOM (mode %s)", len(entries), mode),
+		"summary": summary,
+	}
+	if warning := warnIfReleased(c, db, bomCodes...); warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusCreated, response)
+}
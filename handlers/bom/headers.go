@@ -0,0 +1,222 @@
+package bom
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"yolo-server/handlers/part"
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const headerColumns = `
+	h.bom_code, h.name, h.product_code, h.customer, h.production_line, h.owner, h.description, h.tags,
+	(SELECT COUNT(*) FROM boms b WHERE b.bom_code = h.bom_code), h.created_at, h.updated_at`
+
+func scanHeader(row interface{ Scan(...any) error }, h *models.BOMHeader) error {
+	return row.Scan(&h.BomCode, &h.Name, &h.ProductCode, &h.Customer, &h.ProductionLine, &h.Owner, &h.Description,
+		pq.Array(&h.Tags), &h.LineCount, &h.CreatedAt, &h.UpdatedAt)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// ensureHeader creates an empty header for a BOM code the first time a line is
+// written for it, so line writes never fail on the foreign key.
+func ensureHeader(e execer, bomCode string) error {
+	_, err := e.Exec("INSERT INTO bom_headers (bom_code) VALUES ($1) ON CONFLICT (bom_code) DO NOTHING", bomCode)
+	return err
+}
+
+// Exists reports whether a BOM header with the given code exists.
+func Exists(db *sql.DB, bomCode string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM bom_headers WHERE bom_code = $1)", bomCode).Scan(&exists)
+	return exists, err
+}
+
+func getHeader(db *sql.DB, bomCode string) (models.BOMHeader, error) {
+	var h models.BOMHeader
+	err := scanHeader(db.QueryRow("SELECT "+headerColumns+" FROM bom_headers h WHERE h.bom_code = $1", bomCode), &h)
+	return h, err
+}
+
+// GetBOMHeaders lists BOM headers. ?q= searches code, name and product code;
+// customer, productionLine, owner and tag filter on exact values.
+func GetBOMHeaders(c *gin.Context, db *sql.DB) {
+	var filter bomFilter
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		filter.add("(h.bom_code ILIKE ? OR h.name ILIKE ? OR h.product_code ILIKE ?)", part.ContainsPattern(q))
+	}
+	if customer := c.Query("customer"); customer != "" {
+		filter.add("h.customer = ?", customer)
+	}
+	if line := c.Query("productionLine"); line != "" {
+		filter.add("h.production_line = ?", line)
+	}
+	if owner := c.Query("owner"); owner != "" {
+		filter.add("h.owner = ?", owner)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		filter.add("? = ANY(h.tags)", tag)
+	}
+
+	rows, err := db.Query("SELECT "+headerColumns+" FROM bom_headers h"+filter.where()+" ORDER BY h.bom_code", filter.args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil header BOM"})
+		return
+	}
+	defer rows.Close()
+
+	headers := []models.BOMHeader{}
+	for rows.Next() {
+		var h models.BOMHeader
+		if err := scanHeader(rows, &h); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai header BOM"})
+			return
+		}
+		headers = append(headers, h)
+	}
+	c.JSON(http.StatusOK, headers)
+}
+
+func GetBOMHeader(c *gin.Context, db *sql.DB) {
+	h, err := getHeader(db, c.Param("bomCode"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil header BOM"})
+		return
+	}
+	c.JSON(http.StatusOK, h)
+}
+
+func CreateBOMHeader(c *gin.Context, db *sql.DB) {
+	var h models.BOMHeader
+	if err := c.ShouldBindJSON(&h); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+	h.BomCode = strings.TrimSpace(h.BomCode)
+	if h.BomCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field BomCode wajib diisi"})
+		return
+	}
+	if h.Tags == nil {
+		h.Tags = []string{}
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO bom_headers (bom_code, name, product_code, customer, production_line, owner, description, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, h.BomCode, h.Name, h.ProductCode, h.Customer, h.ProductionLine, h.Owner, h.Description, pq.Array(h.Tags))
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "BOM " + h.BomCode + " sudah ada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan header BOM: " + err.Error()})
+		return
+	}
+
+	created, err := getHeader(db, h.BomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil header BOM"})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateBOMHeader(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("bomCode")
+
+	var patch models.BOMHeaderPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+
+	h, err := getHeader(db, bomCode)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil header BOM"})
+		return
+	}
+
+	if patch.Name != nil {
+		h.Name = *patch.Name
+	}
+	if patch.ProductCode != nil {
+		h.ProductCode = *patch.ProductCode
+	}
+	if patch.Customer != nil {
+		h.Customer = *patch.Customer
+	}
+	if patch.ProductionLine != nil {
+		h.ProductionLine = *patch.ProductionLine
+	}
+	if patch.Owner != nil {
+		h.Owner = *patch.Owner
+	}
+	if patch.Description != nil {
+		h.Description = *patch.Description
+	}
+	if patch.Tags != nil {
+		h.Tags = *patch.Tags
+	}
+	if h.Tags == nil {
+		h.Tags = []string{}
+	}
+
+	_, err = db.Exec(`
+		UPDATE bom_headers
+		SET name = $1, product_code = $2, customer = $3, production_line = $4, owner = $5, description = $6, tags = $7
+		WHERE bom_code = $8
+	`, h.Name, h.ProductCode, h.Customer, h.ProductionLine, h.Owner, h.Description, pq.Array(h.Tags), bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui header BOM: " + err.Error()})
+		return
+	}
+
+	updated, err := getHeader(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil header BOM"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteBOMHeader removes a header that has no lines; DELETE /api/boms/code/:bomCode
+// removes a BOM with its lines.
+func DeleteBOMHeader(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("bomCode")
+
+	h, err := getHeader(db, bomCode)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil header BOM"})
+		return
+	}
+	if h.LineCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "BOM " + bomCode + " masih memiliki baris, hapus baris terlebih dahulu"})
+		return
+	}
+
+	if _, err := db.Exec("DELETE FROM bom_headers WHERE bom_code = $1", bomCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus header BOM"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Header BOM " + bomCode + " berhasil dihapus"})
+}
@@ -0,0 +1,184 @@
+package bom
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryRower and querier are satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// validateComponents checks that every sub-assembly referenced by entries exists
+// and that none of them (transitively) contains its parent again. Run it after
+// writing the entries, inside the same transaction, so the new lines are seen.
+func validateComponents(q queryRower, entries []models.BOMEntry) error {
+	for _, entry := range entries {
+		if entry.ComponentBomCode == "" {
+			continue
+		}
+
+		var exists bool
+		if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM boms WHERE bom_code = $1)", entry.ComponentBomCode).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("komponen BOM %s pada %s tidak ditemukan", entry.ComponentBomCode, entry.BomCode)
+		}
+
+		var cycle bool
+		err := q.QueryRow(`
+			WITH RECURSIVE reachable(code) AS (
+				SELECT $1::VARCHAR
+				UNION
+				SELECT b.component_bom_code FROM boms b
+				JOIN reachable r ON b.bom_code = r.code
+				WHERE b.component_bom_code IS NOT NULL
+			)
+			SELECT EXISTS (SELECT 1 FROM reachable WHERE code = $2)
+		`, entry.ComponentBomCode, entry.BomCode).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("komponen BOM %s membentuk siklus dengan %s", entry.ComponentBomCode, entry.BomCode)
+		}
+	}
+	return nil
+}
+
+// parentBOMs returns the codes of the BOMs that use bomCode as a sub-assembly.
+func parentBOMs(q querier, bomCode string) ([]string, error) {
+	rows, err := q.Query("SELECT DISTINCT bom_code FROM boms WHERE component_bom_code = $1 ORDER BY bom_code", bomCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := []string{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		parents = append(parents, code)
+	}
+	return parents, rows.Err()
+}
+
+// rejectIfComponent writes a 409 response listing the parent BOMs and returns
+// true when bomCode is still used as a sub-assembly.
+func rejectIfComponent(c *gin.Context, db *sql.DB, bomCode string) bool {
+	parents, err := parentBOMs(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa pemakaian BOM sebagai sub-assembly"})
+		return true
+	}
+	if len(parents) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  fmt.Sprintf("BOM %s masih dipakai sebagai sub-assembly, hapus dulu barisnya di BOM induk", bomCode),
+			"usedBy": parents,
+		})
+		return true
+	}
+	return false
+}
+
+// ExplodeLines replaces every sub-assembly line with the leaf parts of that BOM,
+// multiplying quantities through each level. Sub-assemblies use the lines in effect
+// at the given time. Leaf parts that appear more than once are summed.
+func ExplodeLines(db *sql.DB, lines []models.BOMEntry, at time.Time) ([]models.BOMEntry, error) {
+	var leaves []models.BOMEntry
+	byKey := make(map[string]int)
+	var walk func(lines []models.BOMEntry, multiplier int, path map[string]bool) error
+	walk = func(lines []models.BOMEntry, multiplier int, path map[string]bool) error {
+		for _, line := range lines {
+			if line.ComponentBomCode == "" {
+				key := lineKey(line)
+				if i, ok := byKey[key]; ok {
+					leaves[i].Quantity += line.Quantity * multiplier
+					continue
+				}
+				leaf := line
+				leaf.ID = 0
+				leaf.Quantity = line.Quantity * multiplier
+				byKey[key] = len(leaves)
+				leaves = append(leaves, leaf)
+				continue
+			}
+
+			// Revisions are snapshots, so guard against cycles here as well.
+			if path[line.ComponentBomCode] {
+				return fmt.Errorf("komponen BOM %s membentuk siklus", line.ComponentBomCode)
+			}
+			children, _, err := EffectiveLines(db, line.ComponentBomCode, at)
+			if err != nil {
+				return err
+			}
+			if len(children) == 0 {
+				return fmt.Errorf("komponen BOM %s tidak ditemukan", line.ComponentBomCode)
+			}
+			path[line.ComponentBomCode] = true
+			if err := walk(children, multiplier*line.Quantity, path); err != nil {
+				return err
+			}
+			delete(path, line.ComponentBomCode)
+		}
+		return nil
+	}
+
+	root := make(map[string]bool)
+	if len(lines) > 0 {
+		root[lines[0].BomCode] = true
+	}
+	if err := walk(lines, 1, root); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// ExplodeBOM returns the flattened leaf-part quantities of a BOM.
+func ExplodeBOM(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+
+	lines, revision, err := EffectiveLines(db, bomCode, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+	if len(lines) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+
+	leaves, err := ExplodeLines(db, lines, time.Now())
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].PartName < leaves[j].PartName })
+
+	totalQuantity := 0
+	for _, leaf := range leaves {
+		totalQuantity += leaf.Quantity
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bomCode":       bomCode,
+		"bomRevision":   revision,
+		"parts":         leaves,
+		"totalQuantity": totalQuantity,
+	})
+}
@@ -0,0 +1,632 @@
+package bom
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"yolo-server/handlers/part"
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importLine is a data row that passed validation and may be written.
+type importLine struct {
+	index int // position in ImportReport.Rows
+	entry models.BOMEntry
+}
+
+// importColumns are the fields an import file can provide, in the order of the
+// legacy positional layout and of the export files.
+var importColumns = []string{"bom_code", "part_reference", "part_name", "part_description", "quantity", "component_bom_code"}
+
+var requiredImportColumns = []string{"bom_code", "part_name", "quantity"}
+
+// columnMap maps an import column to its index in the file.
+type columnMap map[string]int
+
+// normalizeHeader lowercases a header cell and drops separators, so that
+// "Part Name", "part_name" and "partName" are the same column.
+func normalizeHeader(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '_', '-', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// importSynonyms lists other header names the ERP exports we receive use for
+// each import column, most specific first. Names are compared normalized.
+var importSynonyms = map[string][]string{
+	"bom_code":           {"bom", "bom_no", "bom_number", "kode_bom", "no_bom", "parent_part", "parent", "assembly", "product_code"},
+	"part_reference":     {"part_no", "part_number", "item_code", "item_no", "item_number", "material_number", "material", "kode_part", "no_part", "reference", "ref"},
+	"part_name":          {"item_name", "component_name", "nama_part", "nama_barang", "name", "component", "item", "part"},
+	"part_description":   {"item_description", "material_description", "description", "deskripsi", "keterangan", "desc"},
+	"quantity":           {"qty_per", "quantity_per", "component_quantity", "qty", "jumlah", "kuantitas"},
+	"component_bom_code": {"component_bom", "sub_assembly", "kode_sub_assembly"},
+}
+
+func isImportColumn(col string) bool {
+	for _, known := range importColumns {
+		if col == known {
+			return true
+		}
+	}
+	return false
+}
+
+// mapColumns finds the import columns in a header row. A column named in the
+// profile must use exactly that header; other columns are matched by their own
+// name or a synonym, preferring the closest name when several headers match.
+// Files whose header has none of the known names are read by position, as the
+// first CSV format was.
+func mapColumns(header []string, profile map[string]string) (columnMap, error) {
+	type candidate struct {
+		col  string
+		rank int
+	}
+	known := make(map[string]candidate)
+	for _, col := range importColumns {
+		if name, ok := profile[col]; ok {
+			known[normalizeHeader(name)] = candidate{col: col}
+			continue
+		}
+		if _, taken := known[normalizeHeader(col)]; !taken {
+			known[normalizeHeader(col)] = candidate{col: col}
+		}
+		for i, name := range importSynonyms[col] {
+			if _, taken := known[normalizeHeader(name)]; !taken {
+				known[normalizeHeader(name)] = candidate{col: col, rank: i + 1}
+			}
+		}
+	}
+	// Profile headers win over synonyms of other columns.
+	for col, name := range profile {
+		known[normalizeHeader(name)] = candidate{col: col}
+	}
+
+	cols := make(columnMap)
+	ranks := make(map[string]int)
+	for i, name := range header {
+		match, ok := known[normalizeHeader(name)]
+		if !ok {
+			continue
+		}
+		if rank, dup := ranks[match.col]; dup {
+			if rank == match.rank {
+				return nil, fmt.Errorf("kolom %s muncul lebih dari sekali di header", match.col)
+			}
+			if rank < match.rank {
+				continue
+			}
+		}
+		cols[match.col] = i
+		ranks[match.col] = match.rank
+	}
+
+	for col, name := range profile {
+		if _, ok := cols[col]; !ok {
+			return nil, fmt.Errorf("header %q untuk kolom %s dari profil tidak ditemukan", name, col)
+		}
+	}
+	if len(cols) == 0 {
+		for i, col := range importColumns {
+			cols[col] = i
+		}
+		return cols, nil
+	}
+
+	var missing []string
+	for _, col := range requiredImportColumns {
+		if _, ok := cols[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("kolom wajib tidak ditemukan di header: %s", strings.Join(missing, ", "))
+	}
+	return cols, nil
+}
+
+// cell returns the trimmed value of a column, or "" when the row is too short
+// or the file has no such column.
+func (cols columnMap) cell(record []string, col string) string {
+	i, ok := cols[col]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// parseImportRecord turns one data row into a BOM entry and matches it with the
+// parts catalog. A non-empty reason means the row cannot be imported; skip marks
+// rows that are simply blank.
+func parseImportRecord(record []string, cols columnMap, cat *part.Catalog) (entry models.BOMEntry, match *models.CatalogMatch, reason string, skip bool) {
+	if strings.TrimSpace(strings.Join(record, "")) == "" {
+		return entry, nil, "Baris kosong", true
+	}
+
+	entry = models.BOMEntry{
+		BomCode:          cols.cell(record, "bom_code"),
+		PartReference:    cols.cell(record, "part_reference"),
+		PartName:         cols.cell(record, "part_name"),
+		PartDescription:  cols.cell(record, "part_description"),
+		ComponentBomCode: cols.cell(record, "component_bom_code"),
+	}
+
+	if entry.ComponentBomCode == "" {
+		match = cat.Match(entry.PartReference, entry.PartName)
+		if match != nil && match.Resolved {
+			entry.PartReference = match.PartNumber
+			entry.PartName = match.Name
+		}
+		cat.Fill(&entry)
+	}
+
+	rawQuantity := cols.cell(record, "quantity")
+	if rawQuantity == "" {
+		return entry, match, "Quantity kosong", false
+	}
+	quantity, err := strconv.Atoi(rawQuantity)
+	if err != nil {
+		return entry, match, fmt.Sprintf("Quantity bukan angka: %q", rawQuantity), false
+	}
+	entry.Quantity = quantity
+	if err := validateBOMEntry(entry); err != nil {
+		return entry, match, err.Error(), false
+	}
+	return entry, match, "", false
+}
+
+// importTable is the header and data rows of an uploaded file, whatever its format.
+type importTable struct {
+	format    string
+	sheet     string
+	delimiter string
+	encoding  string
+	header    []string
+	rows      []tableRow
+}
+
+// tableRow is one data row. line is the row number in the file, counting the
+// header as 1; err is set when the row itself could not be parsed.
+type tableRow struct {
+	line  int
+	cells []string
+	err   string
+}
+
+var errEmptyImport = errors.New("file kosong")
+
+// importOptions controls how an uploaded file is read. Empty fields are
+// detected from the file, or default to the first worksheet.
+type importOptions struct {
+	sheet     string
+	delimiter string
+	encoding  string
+}
+
+// readImportTable reads an uploaded CSV or Excel file. Excel files are detected
+// by their .xlsx extension.
+func readImportTable(fileHeader *multipart.FileHeader, opts importOptions) (*importTable, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(fileHeader.Filename), ".xlsx") {
+		return readXLSXTable(file, opts.sheet)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	data, encoding, err := decodeCSV(data, opts.encoding)
+	if err != nil {
+		return nil, err
+	}
+	delimiter := opts.delimiter
+	if delimiter == "" {
+		delimiter = sniffDelimiter(data)
+	}
+
+	table, err := readCSVTable(bytes.NewReader(data), []rune(delimiter)[0])
+	if err != nil {
+		return nil, err
+	}
+	table.encoding = encoding
+	table.delimiter = delimiter
+	return table, nil
+}
+
+func readCSVTable(r io.Reader, delimiter rune) (*importTable, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	// Short rows are reported per row instead of failing the whole file.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errEmptyImport
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca header CSV: %w", err)
+	}
+
+	table := &importTable{format: "csv", header: header}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return table, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			table.rows = append(table.rows, tableRow{line: parseErr.StartLine, err: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gagal membaca baris CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		table.rows = append(table.rows, tableRow{line: line, cells: record})
+	}
+}
+
+// parseImportMode validates the ?mode= query parameter, defaulting to append.
+func parseImportMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return models.ImportModeAppend, nil
+	case models.ImportModeAppend, models.ImportModeUpsert, models.ImportModeReplace:
+		return mode, nil
+	}
+	return "", fmt.Errorf("mode import tidak valid: %s (gunakan append, upsert atau replace)", mode)
+}
+
+// ImportBOMs writes the rows of a CSV or Excel file to the BOM table according
+// to ?mode= and reports the outcome of every row. Columns are found by header
+// name or synonym, ?profile= applies a saved import profile and ?sheet= selects
+// the worksheet of an Excel file. Rows are matched with the parts catalog and
+// the report carries the match or a suggestion. With ?dryRun=true nothing is written; with
+// ?strict=true the whole file is rejected if any row has an error. Replace mode is
+// always strict, since a line whose row has an error would otherwise be deleted.
+// Importing into a finalized BOM needs ?force=true.
+func ImportBOMs(c *gin.Context, db *sql.DB) {
+	dryRun := c.Query("dryRun") == "true"
+	strict := c.Query("strict") == "true"
+	mode, err := parseImportMode(c.Query("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if mode == models.ImportModeReplace {
+		strict = true
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak ditemukan"})
+		return
+	}
+
+	var profile models.ImportProfile
+	if name := c.Query("profile"); name != "" {
+		loaded, err := LoadProfile(db, name)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Profil import " + name + " tidak ditemukan"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil profil import"})
+			return
+		}
+		profile = *loaded
+	}
+
+	opts := importOptions{sheet: profile.Sheet, delimiter: profile.Delimiter, encoding: profile.Encoding}
+	if sheet := c.Query("sheet"); sheet != "" {
+		opts.sheet = sheet
+	}
+	table, err := readImportTable(fileHeader, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membaca file: " + err.Error()})
+		return
+	}
+	cols, err := mapColumns(table.header, profile.Columns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	catalog, err := part.LoadCatalog(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil katalog part"})
+		return
+	}
+
+	report := models.ImportReport{
+		Mode:      mode,
+		Profile:   profile.Name,
+		Format:    table.format,
+		Sheet:     table.sheet,
+		Delimiter: table.delimiter,
+		Encoding:  table.encoding,
+		DryRun:    dryRun,
+		Strict:    strict,
+		Rows:      []models.ImportRowResult{},
+	}
+	var lines []importLine
+	for _, tr := range table.rows {
+		if tr.err != "" {
+			report.Rows = append(report.Rows, models.ImportRowResult{Row: tr.line, Status: models.ImportRowError, Reason: tr.err})
+			continue
+		}
+
+		entry, match, reason, skip := parseImportRecord(tr.cells, cols, catalog)
+		row := models.ImportRowResult{
+			Row:           tr.line,
+			Status:        models.ImportRowAccepted,
+			Reason:        reason,
+			BomCode:       entry.BomCode,
+			PartReference: entry.PartReference,
+			PartName:      entry.PartName,
+			Catalog:       match,
+		}
+		switch {
+		case skip:
+			row.Status = models.ImportRowSkipped
+		case reason != "":
+			row.Status = models.ImportRowError
+		default:
+			lines = append(lines, importLine{index: len(report.Rows), entry: entry})
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	lines = skipFileDuplicates(lines, &report)
+
+	var bomCodes []string
+	for _, line := range lines {
+		if !slices.Contains(bomCodes, line.entry.BomCode) {
+			bomCodes = append(bomCodes, line.entry.BomCode)
+		}
+	}
+	if !dryRun && rejectIfFinalized(c, db, bomCodes...) {
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := writeImportLines(tx, lines, mode, &report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan baris: " + err.Error()})
+		return
+	}
+
+	report.Total = len(report.Rows)
+	for _, row := range report.Rows {
+		switch row.Status {
+		case models.ImportRowAccepted:
+			report.Accepted++
+		case models.ImportRowSkipped:
+			report.Skipped++
+		case models.ImportRowError:
+			report.Errors++
+		}
+	}
+
+	if strict && report.Errors > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  fmt.Sprintf("Import dibatalkan: %d baris bermasalah", report.Errors),
+			"report": report,
+		})
+		return
+	}
+	warning := warnIfReleased(c, db, bomCodes...)
+	if dryRun {
+		response := gin.H{
+			"message": fmt.Sprintf("Dry run: %d baris akan diproses.", report.Accepted),
+			"report":  report,
+		}
+		if warning != "" {
+			response["warning"] = warning
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal commit transaksi"})
+		return
+	}
+	report.Committed = true
+
+	response := gin.H{
+		"message": fmt.Sprintf("Import sukses! %d baris berhasil diproses.", report.Accepted),
+		"report":  report,
+	}
+	if warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// skipFileDuplicates marks rows that repeat an earlier row of the file for the
+// same BOM and returns the rows left to write.
+func skipFileDuplicates(lines []importLine, report *models.ImportReport) []importLine {
+	seen := make(map[string]int)
+	var unique []importLine
+	for _, line := range lines {
+		row := &report.Rows[line.index]
+		key := line.entry.BomCode + "\x00" + lineKey(line.entry)
+		if first, ok := seen[key]; ok {
+			row.Status = models.ImportRowSkipped
+			row.Reason = fmt.Sprintf("Duplikat dari baris %d", first)
+			row.DuplicateOfRow = first
+			continue
+		}
+		seen[key] = row.Row
+		unique = append(unique, line)
+	}
+	return unique
+}
+
+// writeImportLines applies the accepted rows and then checks their sub-assembly
+// references, so components defined later in the same file are found. Rows with
+// an invalid component are reported as errors and the rest is applied again.
+func writeImportLines(tx *sql.Tx, lines []importLine, mode string, report *models.ImportReport) error {
+	if _, err := tx.Exec("SAVEPOINT import_lines"); err != nil {
+		return err
+	}
+
+	for {
+		entries := make([]models.BOMEntry, len(lines))
+		for i, line := range lines {
+			entries[i] = line.entry
+		}
+		changes, summary, err := applyLines(tx, entries, mode)
+		if err != nil {
+			return err
+		}
+
+		var valid []importLine
+		for i, line := range lines {
+			row := &report.Rows[line.index]
+			if err := validateComponents(tx, entries[i:i+1]); err != nil {
+				row.Status = models.ImportRowError
+				row.Reason = err.Error()
+				continue
+			}
+			if changes[i].ExistingID != 0 && changes[i].Action == "" {
+				row.Status = models.ImportRowSkipped
+				row.Reason = fmt.Sprintf("Sudah ada di BOM %s (id %d)", line.entry.BomCode, changes[i].ExistingID)
+				row.ExistingID = changes[i].ExistingID
+			} else {
+				row.Action = changes[i].Action
+			}
+			valid = append(valid, line)
+		}
+
+		if len(valid) == len(lines) {
+			report.Summary = summary
+			return nil
+		}
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT import_lines"); err != nil {
+			return err
+		}
+		lines = valid
+	}
+}
+
+// lineChange is what applyLines did with one incoming line. Action is empty
+// when an existing line was left alone in append mode.
+type lineChange struct {
+	Action     string
+	ExistingID int
+}
+
+// applyLines writes entries to the BOM table. Lines are matched with stored lines
+// of the same BOM by part reference, or by part name when there is none.
+func applyLines(tx *sql.Tx, entries []models.BOMEntry, mode string) ([]lineChange, []models.ImportBOMSummary, error) {
+	changes := make([]lineChange, len(entries))
+	summary := []models.ImportBOMSummary{}
+	summaryIndex := make(map[string]int)
+	stored := make(map[string]map[string]models.BOMEntry)
+
+	for i, entry := range entries {
+		idx, ok := summaryIndex[entry.BomCode]
+		if !ok {
+			if err := ensureHeader(tx, entry.BomCode); err != nil {
+				return nil, nil, err
+			}
+			current, err := CurrentLines(tx, entry.BomCode)
+			if err != nil {
+				return nil, nil, err
+			}
+			byKey := make(map[string]models.BOMEntry, len(current))
+			for _, line := range current {
+				byKey[lineKey(line)] = line
+			}
+			stored[entry.BomCode] = byKey
+			idx = len(summary)
+			summaryIndex[entry.BomCode] = idx
+			summary = append(summary, models.ImportBOMSummary{BomCode: entry.BomCode})
+		}
+
+		key := lineKey(entry)
+		old, exists := stored[entry.BomCode][key]
+		delete(stored[entry.BomCode], key)
+		// Import files carry no alternates, so a line without them keeps the stored ones.
+		if exists && entry.Alternates == nil {
+			entry.Alternates = old.Alternates
+		}
+
+		switch {
+		case !exists:
+			_, err := tx.Exec(`
+				INSERT INTO boms (bom_code, part_reference, part_name, part_description, quantity, component_bom_code, alternates)
+				VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+			`, entry.BomCode, entry.PartReference, entry.PartName, entry.PartDescription, entry.Quantity, entry.ComponentBomCode, alternatesValue(entry.Alternates))
+			if err != nil {
+				return nil, nil, err
+			}
+			changes[i].Action = models.ImportActionInserted
+			summary[idx].Inserted++
+		case mode == models.ImportModeAppend:
+			changes[i].ExistingID = old.ID
+			summary[idx].Skipped++
+		case sameLine(old, entry):
+			changes[i] = lineChange{Action: models.ImportActionUnchanged, ExistingID: old.ID}
+			summary[idx].Unchanged++
+		default:
+			_, err := tx.Exec(`
+				UPDATE boms
+				SET part_reference = $1, part_name = $2, part_description = $3, quantity = $4, component_bom_code = NULLIF($5, ''), alternates = $6
+				WHERE id = $7
+			`, entry.PartReference, entry.PartName, entry.PartDescription, entry.Quantity, entry.ComponentBomCode, alternatesValue(entry.Alternates), old.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			changes[i] = lineChange{Action: models.ImportActionUpdated, ExistingID: old.ID}
+			summary[idx].Updated++
+		}
+	}
+
+	if mode == models.ImportModeReplace {
+		for i := range summary {
+			for _, old := range stored[summary[i].BomCode] {
+				if _, err := tx.Exec("DELETE FROM boms WHERE id = $1", old.ID); err != nil {
+					return nil, nil, err
+				}
+				summary[i].Deleted++
+			}
+		}
+	}
+	return changes, summary, nil
+}
+
+func sameLine(a, b models.BOMEntry) bool {
+	return a.PartReference == b.PartReference &&
+		a.PartName == b.PartName &&
+		a.PartDescription == b.PartDescription &&
+		a.Quantity == b.Quantity &&
+		a.ComponentBomCode == b.ComponentBomCode &&
+		slices.Equal(a.Alternates, b.Alternates)
+}
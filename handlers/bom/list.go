@@ -0,0 +1,291 @@
+package bom
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"yolo-server/handlers/part"
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBOMPageSize = 50
+	maxBOMPageSize     = 500
+)
+
+// bomSorts maps the ?sort= values to their ORDER BY columns. Every list ends in
+// b.id so the order is total and a cursor can resume after any row.
+var bomSorts = map[string][]string{
+	"bomCode":       {"b.bom_code", "b.part_name", "b.id"},
+	"partName":      {"b.part_name", "b.id"},
+	"partReference": {"b.part_reference", "b.id"},
+	"quantity":      {"b.quantity", "b.id"},
+	"id":            {"b.id"},
+}
+
+// encodeCursor stores the sort values of the last row of a page.
+func encodeCursor(values []any) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, n int) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Numbers stay strings so ids and quantities reach Postgres unchanged.
+	decoder.UseNumber()
+	var values []any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	if len(values) != n {
+		return nil, fmt.Errorf("cursor has %d values, expected %d", len(values), n)
+	}
+	return values, nil
+}
+
+// bomFilter collects WHERE conditions. Each ? in a condition becomes the
+// numbered placeholder of its single argument.
+type bomFilter struct {
+	conds []string
+	args  []any
+}
+
+func (f *bomFilter) add(cond string, arg any) {
+	f.args = append(f.args, arg)
+	f.conds = append(f.conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(f.args))))
+}
+
+func (f *bomFilter) where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+// parseBoolQuery reads an optional true/false query parameter.
+func parseBoolQuery(c *gin.Context, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s harus true atau false", name)
+	}
+	return &v, nil
+}
+
+// GetBOMs lists BOM lines with their inspection status. Supported query
+// parameters: bomCode, q (part name or reference), hasDetectionResult,
+// isFinalized, sort, order (asc|desc), limit and cursor.
+func GetBOMs(c *gin.Context, db *sql.DB) {
+	sortName := c.DefaultQuery("sort", "bomCode")
+	sortColumns, ok := bomSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sort tidak valid: " + sortName})
+		return
+	}
+	order := strings.ToLower(c.DefaultQuery("order", "asc"))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order harus asc atau desc"})
+		return
+	}
+	limit := defaultBOMPageSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxBOMPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Limit harus antara 1 dan %d", maxBOMPageSize)})
+			return
+		}
+		limit = n
+	}
+
+	var filter bomFilter
+	if code := c.Query("bomCode"); code != "" {
+		exists, err := Exists(db, code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa BOM"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "BOM " + code + " tidak ditemukan"})
+			return
+		}
+		filter.add("b.bom_code = ?", code)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		filter.add("(b.part_name ILIKE ? OR b.part_reference ILIKE ?)", part.ContainsPattern(q))
+	}
+	hasResult, err := parseBoolQuery(c, "hasDetectionResult")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if hasResult != nil {
+		filter.add("(dr.bom_code IS NOT NULL) = ?", *hasResult)
+	}
+	finalized, err := parseBoolQuery(c, "isFinalized")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if finalized != nil {
+		filter.add("COALESCE(dr.is_finalized, FALSE) = ?", *finalized)
+	}
+
+	from := " FROM boms b LEFT JOIN detection_results dr ON b.bom_code = dr.bom_code"
+
+	var page models.BOMPage
+	if err := db.QueryRow("SELECT COUNT(*)"+from+filter.where(), filter.args...).Scan(&page.Total); err != nil {
+		log.Printf("Error counting BOMs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		values, err := decodeCursor(cursor, len(sortColumns))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor tidak valid"})
+			return
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			filter.args = append(filter.args, v)
+			placeholders[i] = "$" + strconv.Itoa(len(filter.args))
+		}
+		op := ">"
+		if order == "desc" {
+			op = "<"
+		}
+		filter.conds = append(filter.conds, fmt.Sprintf("(%s) %s (%s)", strings.Join(sortColumns, ", "), op, strings.Join(placeholders, ", ")))
+	}
+
+	orderBy := make([]string, len(sortColumns))
+	for i, col := range sortColumns {
+		orderBy[i] = col + " " + order
+	}
+	query := `
+		SELECT
+			b.id, b.bom_code, b.part_reference, b.part_name, COALESCE(b.part_description, ''), b.quantity,
+			COALESCE(b.component_bom_code, ''), b.alternates,
+			dr.bom_code IS NOT NULL AS has_detection_result,
+			COALESCE(dr.is_finalized, FALSE) AS is_finalized` +
+		from + filter.where() +
+		" ORDER BY " + strings.Join(orderBy, ", ") +
+		fmt.Sprintf(" LIMIT %d", limit+1)
+
+	rows, err := db.Query(query, filter.args...)
+	if err != nil {
+		log.Printf("Error querying BOMs with status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+	defer rows.Close()
+
+	page.Items = []models.BOMEntryWithStatus{}
+	for rows.Next() {
+		var bom models.BOMEntryWithStatus
+		if err := rows.Scan(
+			&bom.ID, &bom.BomCode, &bom.PartReference, &bom.PartName,
+			&bom.PartDescription, &bom.Quantity, &bom.ComponentBomCode, alternatesColumn{&bom.Alternates},
+			&bom.HasDetectionResult, &bom.IsFinalized,
+		); err != nil {
+			log.Printf("Error scanning BOM row: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai baris data BOM"})
+			return
+		}
+		page.Items = append(page.Items, bom)
+	}
+
+	// One extra row was fetched to learn whether another page follows.
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := page.Items[limit-1]
+		sortValues := map[string]any{
+			"b.bom_code":       last.BomCode,
+			"b.part_name":      last.PartName,
+			"b.part_reference": last.PartReference,
+			"b.quantity":       last.Quantity,
+			"b.id":             last.ID,
+		}
+		values := make([]any, len(sortColumns))
+		for i, col := range sortColumns {
+			values[i] = sortValues[col]
+		}
+		page.NextCursor, err = encodeCursor(values)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat cursor"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetBOMSummary returns one row per BOM code with its line count, total
+// quantity and inspection status. ?q= filters on the BOM code.
+func GetBOMSummary(c *gin.Context, db *sql.DB) {
+	query := `
+		SELECT
+			b.bom_code,
+			COUNT(*),
+			SUM(b.quantity),
+			dr.bom_code IS NOT NULL,
+			COALESCE(dr.is_finalized, FALSE),
+			COALESCE(jsonb_array_length(dr.comparison_result_json->'shortageItems'), 0),
+			COALESCE(jsonb_array_length(dr.comparison_result_json->'surplusItems'), 0),
+			dr.updated_at
+		FROM boms b
+		LEFT JOIN detection_results dr ON b.bom_code = dr.bom_code
+		WHERE $1 = '' OR b.bom_code ILIKE $2
+		GROUP BY b.bom_code, dr.id
+		ORDER BY b.bom_code
+	`
+	q := strings.TrimSpace(c.Query("q"))
+	rows, err := db.Query(query, q, part.ContainsPattern(q))
+	if err != nil {
+		log.Printf("Error querying BOM summary: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil ringkasan BOM"})
+		return
+	}
+	defer rows.Close()
+
+	summaries := []models.BOMSummary{}
+	for rows.Next() {
+		var s models.BOMSummary
+		if err := rows.Scan(
+			&s.BomCode, &s.LineCount, &s.TotalQuantity, &s.HasDetectionResult, &s.IsFinalized,
+			&s.ShortageCount, &s.SurplusCount, &s.LastInspectedAt,
+		); err != nil {
+			log.Printf("Error scanning BOM summary row: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai ringkasan BOM"})
+			return
+		}
+		switch {
+		case s.IsFinalized:
+			s.InspectionStatus = models.InspectionFinalized
+		case s.HasDetectionResult:
+			s.InspectionStatus = models.InspectionInspected
+		default:
+			s.InspectionStatus = models.InspectionNotInspected
+		}
+		summaries = append(summaries, s)
+	}
+	c.JSON(http.StatusOK, summaries)
+}
@@ -0,0 +1,204 @@
+package bom
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const profileColumns = "id, name, delimiter, encoding, sheet, columns, created_at, updated_at"
+
+func scanProfile(row interface{ Scan(...any) error }, p *models.ImportProfile) error {
+	var columns []byte
+	if err := row.Scan(&p.ID, &p.Name, &p.Delimiter, &p.Encoding, &p.Sheet, &columns, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(columns, &p.Columns)
+}
+
+func validateProfile(p models.ImportProfile) string {
+	if strings.TrimSpace(p.Name) == "" {
+		return "Nama profil wajib diisi"
+	}
+	if p.Delimiter != "" && (len([]rune(p.Delimiter)) != 1 || !strings.Contains(csvDelimiters, p.Delimiter)) {
+		return fmt.Sprintf("Delimiter tidak valid: %q (gunakan , ; | atau tab)", p.Delimiter)
+	}
+	switch p.Encoding {
+	case "", encodingUTF8, encodingWindows1252:
+	default:
+		return fmt.Sprintf("Encoding tidak valid: %s (gunakan %s atau %s)", p.Encoding, encodingUTF8, encodingWindows1252)
+	}
+	for col, header := range p.Columns {
+		if !isImportColumn(col) {
+			return fmt.Sprintf("Kolom tidak dikenal: %s (gunakan %s)", col, strings.Join(importColumns, ", "))
+		}
+		if strings.TrimSpace(header) == "" {
+			return fmt.Sprintf("Header untuk kolom %s wajib diisi", col)
+		}
+	}
+	return ""
+}
+
+// LoadProfile returns the import profile with the given name.
+func LoadProfile(db *sql.DB, name string) (*models.ImportProfile, error) {
+	var p models.ImportProfile
+	if err := scanProfile(db.QueryRow("SELECT "+profileColumns+" FROM import_profiles WHERE name = $1", name), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func GetImportProfiles(c *gin.Context, db *sql.DB) {
+	rows, err := db.Query("SELECT " + profileColumns + " FROM import_profiles ORDER BY name")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil profil import"})
+		return
+	}
+	defer rows.Close()
+
+	profiles := []models.ImportProfile{}
+	for rows.Next() {
+		var p models.ImportProfile
+		if err := scanProfile(rows, &p); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai profil import"})
+			return
+		}
+		profiles = append(profiles, p)
+	}
+	c.JSON(http.StatusOK, profiles)
+}
+
+func CreateImportProfile(c *gin.Context, db *sql.DB) {
+	var p models.ImportProfile
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+	if msg := validateProfile(p); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if p.Columns == nil {
+		p.Columns = map[string]string{}
+	}
+	columns, err := json.Marshal(p.Columns)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses mapping kolom"})
+		return
+	}
+
+	query := `
+		INSERT INTO import_profiles (name, delimiter, encoding, sheet, columns)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + profileColumns
+	if err := scanProfile(db.QueryRow(query, p.Name, p.Delimiter, p.Encoding, p.Sheet, columns), &p); err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Profil import " + p.Name + " sudah ada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan profil import: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+func UpdateImportProfile(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID profil tidak valid"})
+		return
+	}
+
+	var patch struct {
+		Name      *string            `json:"name"`
+		Delimiter *string            `json:"delimiter"`
+		Encoding  *string            `json:"encoding"`
+		Sheet     *string            `json:"sheet"`
+		Columns   *map[string]string `json:"columns"`
+	}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+
+	var p models.ImportProfile
+	err = scanProfile(db.QueryRow("SELECT "+profileColumns+" FROM import_profiles WHERE id = $1", id), &p)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profil import tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil profil import"})
+		return
+	}
+
+	if patch.Name != nil {
+		p.Name = *patch.Name
+	}
+	if patch.Delimiter != nil {
+		p.Delimiter = *patch.Delimiter
+	}
+	if patch.Encoding != nil {
+		p.Encoding = *patch.Encoding
+	}
+	if patch.Sheet != nil {
+		p.Sheet = *patch.Sheet
+	}
+	if patch.Columns != nil {
+		p.Columns = *patch.Columns
+	}
+	if p.Columns == nil {
+		p.Columns = map[string]string{}
+	}
+	if msg := validateProfile(p); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	columns, err := json.Marshal(p.Columns)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses mapping kolom"})
+		return
+	}
+
+	query := `
+		UPDATE import_profiles
+		SET name = $1, delimiter = $2, encoding = $3, sheet = $4, columns = $5
+		WHERE id = $6
+		RETURNING ` + profileColumns
+	if err := scanProfile(db.QueryRow(query, p.Name, p.Delimiter, p.Encoding, p.Sheet, columns, id), &p); err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Profil import " + p.Name + " sudah ada"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui profil import: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func DeleteImportProfile(c *gin.Context, db *sql.DB) {
+	result, err := db.Exec("DELETE FROM import_profiles WHERE id = $1", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus profil import"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa baris yang terpengaruh"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profil import tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Profil import berhasil dihapus"})
+}
@@ -0,0 +1,495 @@
+package bom
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CurrentLines returns the lines of a BOM as they are in the boms table now.
+func CurrentLines(db querier, bomCode string) ([]models.BOMEntry, error) {
+	rows, err := db.Query("SELECT id, bom_code, part_reference, part_name, COALESCE(part_description, ''), quantity, COALESCE(component_bom_code, ''), alternates FROM boms WHERE bom_code = $1 ORDER BY id", bomCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.BOMEntry
+	for rows.Next() {
+		var item models.BOMEntry
+		if err := rows.Scan(&item.ID, &item.BomCode, &item.PartReference, &item.PartName, &item.PartDescription, &item.Quantity, &item.ComponentBomCode, alternatesColumn{&item.Alternates}); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// effectiveRevisionQuery selects the number and lines of the released revision
+// of BOM $1 in effect at $2.
+const effectiveRevisionQuery = `
+	SELECT revision, lines FROM bom_revisions
+	WHERE bom_code = $1 AND status = 'RELEASED'
+		AND (effective_from IS NULL OR effective_from <= $2)
+		AND (effective_to IS NULL OR effective_to > $2)
+	ORDER BY revision DESC
+	LIMIT 1
+`
+
+// EffectiveLines returns the lines of the released revision in effect at the given
+// time together with its number, or the current lines and a nil revision when no
+// released revision applies.
+func EffectiveLines(db *sql.DB, bomCode string, at time.Time) ([]models.BOMEntry, *int, error) {
+	var revision int
+	var linesJSON []byte
+	err := db.QueryRow(effectiveRevisionQuery, bomCode, at).Scan(&revision, &linesJSON)
+	if err == sql.ErrNoRows {
+		lines, err := CurrentLines(db, bomCode)
+		return lines, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lines []models.BOMEntry
+	if err := json.Unmarshal(linesJSON, &lines); err != nil {
+		return nil, nil, err
+	}
+	return lines, &revision, nil
+}
+
+// warnIfReleased tells the client, in a Warning header and the returned text,
+// when edited BOMs are compared through a released revision, so the edit does not
+// reach detection until a new revision is released. It returns "" otherwise.
+func warnIfReleased(c *gin.Context, db *sql.DB, bomCodes ...string) string {
+	var warnings []string
+	for _, bomCode := range bomCodes {
+		var revision int
+		var linesJSON []byte
+		err := db.QueryRow(effectiveRevisionQuery, bomCode, time.Now()).Scan(&revision, &linesJSON)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			log.Printf("Gagal memeriksa revisi BOM %s: %v", bomCode, err)
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("BOM %s dibandingkan dengan revisi %d yang sudah dirilis; perubahan baris baru dipakai deteksi setelah revisi baru dirilis", bomCode, revision))
+	}
+	if len(warnings) == 0 {
+		return ""
+	}
+	warning := strings.Join(warnings, ". ")
+	c.Header("Warning", `299 - "`+warning+`"`)
+	return warning
+}
+
+// LinesForRevision returns the lines of one revision, or the current lines when
+// revision is nil.
+func LinesForRevision(db *sql.DB, bomCode string, revision *int) ([]models.BOMEntry, error) {
+	if revision == nil {
+		return CurrentLines(db, bomCode)
+	}
+	rev, err := getRevision(db, bomCode, *revision)
+	if err != nil {
+		return nil, err
+	}
+	return rev.Lines, nil
+}
+
+const revisionColumns = "id, bom_code, revision, status, effective_from, effective_to, lines, COALESCE(note, ''), created_at, released_at"
+
+func scanRevision(row interface{ Scan(...any) error }) (models.BOMRevision, error) {
+	var rev models.BOMRevision
+	var linesJSON []byte
+	var effectiveFrom, effectiveTo, releasedAt sql.NullTime
+	if err := row.Scan(
+		&rev.ID, &rev.BomCode, &rev.Revision, &rev.Status, &effectiveFrom, &effectiveTo,
+		&linesJSON, &rev.Note, &rev.CreatedAt, &releasedAt,
+	); err != nil {
+		return rev, err
+	}
+	if effectiveFrom.Valid {
+		rev.EffectiveFrom = &effectiveFrom.Time
+	}
+	if effectiveTo.Valid {
+		rev.EffectiveTo = &effectiveTo.Time
+	}
+	if releasedAt.Valid {
+		rev.ReleasedAt = &releasedAt.Time
+	}
+	if err := json.Unmarshal(linesJSON, &rev.Lines); err != nil {
+		return rev, err
+	}
+	rev.LineCount = len(rev.Lines)
+	return rev, nil
+}
+
+func getRevision(db *sql.DB, bomCode string, revision int) (models.BOMRevision, error) {
+	row := db.QueryRow("SELECT "+revisionColumns+" FROM bom_revisions WHERE bom_code = $1 AND revision = $2", bomCode, revision)
+	return scanRevision(row)
+}
+
+func GetRevisions(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+
+	rows, err := db.Query("SELECT "+revisionColumns+" FROM bom_revisions WHERE bom_code = $1 ORDER BY revision DESC", bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil revisi BOM"})
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.BOMRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindai revisi BOM"})
+			return
+		}
+		rev.Lines = nil
+		revisions = append(revisions, rev)
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+func GetRevision(c *gin.Context, db *sql.DB) {
+	revision, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nomor revisi tidak valid"})
+		return
+	}
+
+	rev, err := getRevision(db, c.Param("id"), revision)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revisi tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil revisi BOM"})
+		return
+	}
+	c.JSON(http.StatusOK, rev)
+}
+
+// CreateRevision copies the current lines of a BOM into a new draft revision. The
+// header row is locked so concurrent calls get consecutive revision numbers.
+func CreateRevision(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRow("SELECT bom_code FROM bom_headers WHERE bom_code = $1 FOR UPDATE", bomCode).Scan(&locked)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+
+	lines, err := CurrentLines(tx, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		return
+	}
+	if len(lines) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM tidak ditemukan"})
+		return
+	}
+	linesJSON, err := json.Marshal(lines)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyusun revisi"})
+		return
+	}
+
+	query := `
+		INSERT INTO bom_revisions (bom_code, revision, lines, note)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, NULLIF($3, '')
+		FROM bom_revisions WHERE bom_code = $1
+		RETURNING ` + revisionColumns
+	rev, err := scanRevision(tx.QueryRow(query, bomCode, linesJSON, req.Note))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat revisi: " + err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal commit transaksi"})
+		return
+	}
+	c.JSON(http.StatusCreated, rev)
+}
+
+// UpdateRevision releases a draft, sets effectivity dates or replaces the lines of
+// a draft. Releasing a revision ends the previous open-ended released revision
+// where the new one starts.
+func UpdateRevision(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+	revision, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nomor revisi tidak valid"})
+		return
+	}
+
+	var req models.BOMRevisionPatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+	defer tx.Rollback()
+
+	rev, err := scanRevision(tx.QueryRow("SELECT "+revisionColumns+" FROM bom_revisions WHERE bom_code = $1 AND revision = $2 FOR UPDATE", bomCode, revision))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revisi tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil revisi BOM"})
+		return
+	}
+
+	if req.Lines != nil {
+		if rev.Status != models.RevisionDraft {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Baris revisi yang sudah dirilis tidak bisa diubah, buat revisi baru"})
+			return
+		}
+		lines, err := revisionLines(tx, bomCode, *req.Lines)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rev.Lines = lines
+	}
+
+	releasing := false
+	if req.Status != nil && *req.Status != rev.Status {
+		if *req.Status != models.RevisionReleased {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Revisi yang sudah dirilis tidak bisa dikembalikan ke DRAFT"})
+			return
+		}
+		releasing = true
+		rev.Status = models.RevisionReleased
+	}
+	if req.EffectiveFrom != nil {
+		if rev.Status == models.RevisionReleased && !releasing {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tanggal mulai berlaku revisi yang sudah dirilis tidak bisa diubah"})
+			return
+		}
+		rev.EffectiveFrom = req.EffectiveFrom
+	}
+	if req.EffectiveTo != nil {
+		rev.EffectiveTo = req.EffectiveTo
+	}
+	if req.Note != nil {
+		rev.Note = *req.Note
+	}
+	if releasing && rev.EffectiveFrom == nil {
+		now := time.Now()
+		rev.EffectiveFrom = &now
+	}
+	if rev.EffectiveFrom != nil && rev.EffectiveTo != nil && !rev.EffectiveTo.After(*rev.EffectiveFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effectiveTo harus setelah effectiveFrom"})
+		return
+	}
+
+	if releasing {
+		if _, err := tx.Exec(`
+			UPDATE bom_revisions SET effective_to = $1
+			WHERE bom_code = $2 AND revision < $3 AND status = 'RELEASED'
+				AND effective_to IS NULL AND (effective_from IS NULL OR effective_from < $1)
+		`, rev.EffectiveFrom, bomCode, revision); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menutup revisi sebelumnya"})
+			return
+		}
+	}
+
+	// Lines stay as they are unless the request replaces them.
+	var linesJSON any
+	if req.Lines != nil {
+		if linesJSON, err = json.Marshal(rev.Lines); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyusun revisi"})
+			return
+		}
+	}
+
+	query := `
+		UPDATE bom_revisions
+		SET status = $1, effective_from = $2, effective_to = $3, note = NULLIF($4, ''),
+			released_at = CASE WHEN $5 THEN NOW() ELSE released_at END,
+			lines = COALESCE($6, lines)
+		WHERE id = $7
+		RETURNING ` + revisionColumns
+	rev, err = scanRevision(tx.QueryRow(query, rev.Status, rev.EffectiveFrom, rev.EffectiveTo, rev.Note, releasing, linesJSON, rev.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui revisi: " + err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal commit transaksi"})
+		return
+	}
+	c.JSON(http.StatusOK, rev)
+}
+
+// revisionLines validates new lines for a draft revision of bomCode.
+func revisionLines(tx *sql.Tx, bomCode string, lines []models.BOMEntry) ([]models.BOMEntry, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("Revisi harus memiliki minimal satu baris")
+	}
+	seen := make(map[string]bool)
+	for i := range lines {
+		line := &lines[i]
+		line.ID = 0
+		line.BomCode = bomCode
+		if err := validateBOMEntry(*line); err != nil {
+			return nil, fmt.Errorf("Baris %d tidak valid: %w", i+1, err)
+		}
+		key := lineKey(*line)
+		if seen[key] {
+			return nil, fmt.Errorf("Baris duplikat: part '%s' muncul lebih dari sekali", line.PartName)
+		}
+		seen[key] = true
+	}
+	if err := validateComponents(tx, lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// DiffRevisions compares two revisions line by line. Either side may be "current"
+// to compare against the lines in boms right now.
+func DiffRevisions(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+
+	load := func(param string) ([]models.BOMEntry, error) {
+		value := c.Query(param)
+		if value == "" || value == "current" {
+			return CurrentLines(db, bomCode)
+		}
+		revision, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s harus nomor revisi atau current", param)
+		}
+		rev, err := getRevision(db, bomCode, revision)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("revisi %d tidak ditemukan", revision)
+		}
+		return rev.Lines, err
+	}
+
+	from, err := load("from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := load("to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bomCode": bomCode,
+		"from":    c.DefaultQuery("from", "current"),
+		"to":      c.DefaultQuery("to", "current"),
+		"lines":   diffLines(from, to),
+	})
+}
+
+// lineKey identifies a line across revisions: its part reference, or its name when
+// the reference is empty.
+func lineKey(line models.BOMEntry) string {
+	if line.PartReference != "" {
+		return "ref:" + line.PartReference
+	}
+	return "name:" + line.PartName
+}
+
+func diffLines(from, to []models.BOMEntry) []models.BOMLineDiff {
+	fromByKey := make(map[string]models.BOMEntry)
+	for _, line := range from {
+		fromByKey[lineKey(line)] = line
+	}
+	seen := make(map[string]bool)
+
+	diffs := []models.BOMLineDiff{}
+	for _, line := range to {
+		key := lineKey(line)
+		seen[key] = true
+		newLine := line
+		old, ok := fromByKey[key]
+		if !ok {
+			diffs = append(diffs, models.BOMLineDiff{PartReference: line.PartReference, PartName: line.PartName, Change: "ADDED", To: &newLine})
+			continue
+		}
+
+		oldLine := old
+		var fields []string
+		if old.PartName != line.PartName {
+			fields = append(fields, "partName")
+		}
+		if old.PartDescription != line.PartDescription {
+			fields = append(fields, "partDescription")
+		}
+		if old.Quantity != line.Quantity {
+			fields = append(fields, "quantity")
+		}
+		if old.ComponentBomCode != line.ComponentBomCode {
+			fields = append(fields, "componentBomCode")
+		}
+		if !slices.Equal(old.Alternates, line.Alternates) {
+			fields = append(fields, "alternates")
+		}
+		change := "UNCHANGED"
+		if len(fields) > 0 {
+			change = "CHANGED"
+		}
+		diffs = append(diffs, models.BOMLineDiff{
+			PartReference: line.PartReference, PartName: line.PartName, Change: change,
+			ChangedFields: fields, From: &oldLine, To: &newLine,
+		})
+	}
+
+	for _, line := range from {
+		if !seen[lineKey(line)] {
+			oldLine := line
+			diffs = append(diffs, models.BOMLineDiff{PartReference: line.PartReference, PartName: line.PartName, Change: "REMOVED", From: &oldLine})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,92 @@
+package bom
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"yolo-server/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// readXLSXTable reads a worksheet of an Excel file. The first non-empty row is
+// the header; later empty rows are ignored, as blank CSV lines are.
+func readXLSXTable(r io.Reader, sheet string) (*importTable, error) {
+	// Raw values keep quantities free of number formatting such as "1,000".
+	f, err := excelize.OpenReader(r, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("file Excel tidak valid: %w", err)
+	}
+	defer f.Close()
+
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errEmptyImport
+		}
+		sheet = sheets[0]
+	} else if idx, err := f.GetSheetIndex(sheet); err != nil || idx == -1 {
+		return nil, fmt.Errorf("sheet %s tidak ditemukan", sheet)
+	}
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca sheet %s: %w", sheet, err)
+	}
+
+	table := &importTable{format: "xlsx", sheet: sheet}
+	for i, cells := range rows {
+		if strings.TrimSpace(strings.Join(cells, "")) == "" {
+			continue
+		}
+		if table.header == nil {
+			table.header = cells
+			continue
+		}
+		table.rows = append(table.rows, tableRow{line: i + 1, cells: cells})
+	}
+	if table.header == nil {
+		return nil, errEmptyImport
+	}
+	return table, nil
+}
+
+// writeXLSX writes BOM lines as a single-sheet workbook with the export headers.
+func writeXLSX(w io.Writer, entries []models.BOMEntry) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const sheet = "BOM"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	header := make([]any, len(importColumns))
+	for i, col := range importColumns {
+		header[i] = col
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		row := []any{entry.BomCode, entry.PartReference, entry.PartName, entry.PartDescription, entry.Quantity, entry.ComponentBomCode}
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	return f.Write(w)
+}
@@ -0,0 +1,403 @@
+package detection
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"yolo-server/models"
+)
+
+// bomRequirement is one part of a BOM with the quantities of its lines summed up.
+// Alternates are those of all its lines, in the order they are counted.
+type bomRequirement struct {
+	PartName       string
+	PartReferences []string
+	Required       int
+	Alternates     []models.BOMAlternate
+}
+
+// normalizeName lower-cases a name and drops all whitespace, for loose alias matching.
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+func namesMatch(a, b string, loose bool) bool {
+	if loose {
+		return normalizeName(a) == normalizeName(b)
+	}
+	return a == b
+}
+
+func groupRequirements(bomItems []models.BOMEntry) []*bomRequirement {
+	var requirements []*bomRequirement
+	byName := make(map[string]*bomRequirement)
+	for _, item := range bomItems {
+		req, ok := byName[item.PartName]
+		if !ok {
+			req = &bomRequirement{PartName: item.PartName}
+			byName[item.PartName] = req
+			requirements = append(requirements, req)
+		}
+		if item.PartReference != "" {
+			req.PartReferences = append(req.PartReferences, item.PartReference)
+		}
+		req.Required += item.Quantity
+		for _, alt := range item.Alternates {
+			if !slices.ContainsFunc(req.Alternates, func(a models.BOMAlternate) bool {
+				return a.PartReference == alt.PartReference && a.PartName == alt.PartName
+			}) {
+				req.Alternates = append(req.Alternates, alt)
+			}
+		}
+	}
+	for _, req := range requirements {
+		sort.SliceStable(req.Alternates, func(i, j int) bool { return req.Alternates[i].Priority < req.Alternates[j].Priority })
+	}
+	return requirements
+}
+
+// alternateRequirement describes an alternate part so aliasesForPart can find
+// its classes. A part known only by reference uses the reference as its name.
+func alternateRequirement(alt models.BOMAlternate) *bomRequirement {
+	req := &bomRequirement{PartName: alt.PartName}
+	if alt.PartReference != "" {
+		req.PartReferences = []string{alt.PartReference}
+	}
+	if req.PartName == "" {
+		req.PartName = alt.PartReference
+	}
+	return req
+}
+
+// classAliases returns the aliases of a part, or the part name itself as the
+// expected class when it has none.
+func classAliases(req *bomRequirement, aliases []models.PartClassAlias) ([]models.PartClassAlias, bool) {
+	matched := aliasesForPart(req, aliases)
+	if len(matched) == 0 {
+		return []models.PartClassAlias{{ClassName: req.PartName}}, false
+	}
+	return matched, true
+}
+
+func matchesClass(aliases []models.PartClassAlias, className string) bool {
+	for _, a := range aliases {
+		if namesMatch(a.ClassName, className, a.LooseMatch) {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasesForPart returns the aliases that apply to a BOM part, by reference or by name.
+func aliasesForPart(req *bomRequirement, aliases []models.PartClassAlias) []models.PartClassAlias {
+	var matched []models.PartClassAlias
+	for _, a := range aliases {
+		byReference := false
+		for _, ref := range req.PartReferences {
+			if a.PartReference != "" && namesMatch(a.PartReference, ref, a.LooseMatch) {
+				byReference = true
+				break
+			}
+		}
+		if byReference || (a.PartName != "" && namesMatch(a.PartName, req.PartName, a.LooseMatch)) {
+			matched = append(matched, a)
+		}
+	}
+	return matched
+}
+
+// comparisonRules holds everything besides the BOM and the detections that
+// influences a comparison.
+type comparisonRules struct {
+	Aliases []models.PartClassAlias
+	// Overrides replace the detected quantity of a part, keyed by BOM part name or,
+	// for unlisted detections, by class name.
+	Overrides map[string]models.CountOverride
+	// Tolerances are the rules of the BOM being compared and the global ones.
+	Tolerances []models.ToleranceRule
+	// MinConfidence is the lowest trusted average confidence per class name.
+	MinConfidence map[string]float64
+}
+
+// partConfidence accumulates the confidence of the detections counted toward a part.
+type partConfidence struct {
+	weighted float64
+	quantity int
+	low      bool
+}
+
+func (p *partConfidence) add(className string, quantity int, confidence float64, minConfidence map[string]float64) {
+	if quantity <= 0 {
+		return
+	}
+	p.weighted += confidence * float64(quantity)
+	p.quantity += quantity
+	if threshold, ok := minConfidence[className]; ok && confidence < threshold {
+		p.low = true
+	}
+}
+
+// average is the confidence weighted by quantity, or nil when nothing was counted.
+func (p partConfidence) average() *float64 {
+	if p.quantity == 0 {
+		return nil
+	}
+	avg := math.Round(p.weighted/float64(p.quantity)*1000) / 1000
+	return &avg
+}
+
+// toleranceFor returns the most specific tolerance rule for a part, or nil.
+func toleranceFor(req *bomRequirement, rules []models.ToleranceRule) *models.ToleranceRule {
+	var best *models.ToleranceRule
+	bestRank := -1
+	for i, r := range rules {
+		rank := 0
+		if r.PartReference != "" || r.PartName != "" {
+			if r.PartReference != "" && !slices.Contains(req.PartReferences, r.PartReference) {
+				continue
+			}
+			if r.PartName != "" && r.PartName != req.PartName {
+				continue
+			}
+			rank += 2
+		}
+		if r.BomCode != "" {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = &rules[i], rank
+		}
+	}
+	return best
+}
+
+// toleranceLimit is how many pieces a mode and value allow for a required quantity.
+func toleranceLimit(mode string, value float64, required int) float64 {
+	if mode == models.TolerancePercent {
+		return float64(required) * value / 100
+	}
+	return value
+}
+
+// withinTolerance reports whether a non-zero difference is accepted by the rule.
+func withinTolerance(rule *models.ToleranceRule, required, detected int) bool {
+	if rule == nil {
+		return false
+	}
+	if detected < required {
+		return float64(required-detected) <= toleranceLimit(rule.UnderMode, rule.Under, required)
+	}
+	return float64(detected-required) <= toleranceLimit(rule.OverMode, rule.Over, required)
+}
+
+// applyOverride returns the quantity to compare with and, when an inspector
+// overrode it, records the model count alongside.
+func applyOverride(partName string, modelQty int, rules comparisonRules, applied *[]models.CountOverride) (int, *int) {
+	override, ok := rules.Overrides[partName]
+	if !ok {
+		return modelQty, nil
+	}
+	override.PartName = partName
+	override.ModelCount = modelQty
+	*applied = append(*applied, override)
+	modelCount := modelQty
+	return override.OverrideCount, &modelCount
+}
+
+func compareBOMAndDetections(bomItems []models.BOMEntry, detected []models.DetectionSummary, rules comparisonRules) models.ComparisonResult {
+	// remaining is the detected quantity per class not yet counted toward a part.
+	remaining := make(map[string]int)
+	classConfidence := make(map[string]float64)
+	var classNames []string
+	for _, s := range detected {
+		if _, ok := remaining[s.ClassName]; !ok {
+			classNames = append(classNames, s.ClassName)
+		}
+		remaining[s.ClassName] += s.Quantity
+		classConfidence[s.ClassName] += s.AvgConfidence * float64(s.Quantity)
+	}
+	sort.Strings(classNames)
+	for _, className := range classNames {
+		if remaining[className] > 0 {
+			classConfidence[className] /= float64(remaining[className])
+		}
+	}
+
+	consumed := make(map[string]bool)
+	var matched []models.MatchedItem
+	var shortage []models.ShortageItem
+	var surplus []models.SurplusItem
+	var unmapped []string
+	var applied []models.CountOverride
+	needsReview := false
+	var alternatesUsed []models.AlternateUsage
+
+	requirements := groupRequirements(bomItems)
+	sort.Slice(requirements, func(i, j int) bool { return requirements[i].PartName < requirements[j].PartName })
+
+	// Every part first takes detections of its own classes up to its required
+	// quantity, so its primary classes serve it before any alternate.
+	detectedQtys := make([]int, len(requirements))
+	confidences := make([]partConfidence, len(requirements))
+	primaryAliases := make([][]models.PartClassAlias, len(requirements))
+	for i, req := range requirements {
+		partAliases, mapped := classAliases(req, rules.Aliases)
+		if !mapped {
+			unmapped = append(unmapped, req.PartName)
+		}
+		primaryAliases[i] = partAliases
+		for _, className := range classNames {
+			need := req.Required - detectedQtys[i]
+			if need <= 0 {
+				break
+			}
+			if remaining[className] == 0 || !matchesClass(partAliases, className) {
+				continue
+			}
+			taken := min(need, remaining[className])
+			remaining[className] -= taken
+			consumed[className] = true
+			detectedQtys[i] += taken
+			confidences[i].add(className, taken, classConfidence[className], rules.MinConfidence)
+		}
+	}
+
+	// Alternates then make up shortfalls from what is left, taking no more than needed.
+	for i, req := range requirements {
+		for _, alt := range req.Alternates {
+			altReq := alternateRequirement(alt)
+			altAliases, _ := classAliases(altReq, rules.Aliases)
+			for _, className := range classNames {
+				need := req.Required - detectedQtys[i]
+				if need <= 0 {
+					break
+				}
+				if remaining[className] == 0 || !matchesClass(altAliases, className) {
+					continue
+				}
+				taken := min(need, remaining[className])
+				remaining[className] -= taken
+				consumed[className] = true
+				detectedQtys[i] += taken
+				confidences[i].add(className, taken, classConfidence[className], rules.MinConfidence)
+				alternatesUsed = append(alternatesUsed, models.AlternateUsage{
+					PartName:  req.PartName,
+					Alternate: altReq.PartName,
+					ClassName: className,
+					Quantity:  taken,
+				})
+			}
+		}
+	}
+
+	// Whatever is left of a part's own classes is its surplus.
+	for i := range requirements {
+		for _, className := range classNames {
+			if remaining[className] == 0 || !matchesClass(primaryAliases[i], className) {
+				continue
+			}
+			detectedQtys[i] += remaining[className]
+			confidences[i].add(className, remaining[className], classConfidence[className], rules.MinConfidence)
+			remaining[className] = 0
+			consumed[className] = true
+		}
+	}
+
+	for i, req := range requirements {
+		detectedQty, modelDetected := applyOverride(req.PartName, detectedQtys[i], rules, &applied)
+		rule := toleranceFor(req, rules.Tolerances)
+		confidence := confidences[i]
+
+		switch {
+		case detectedQty == req.Required || withinTolerance(rule, req.Required, detectedQty):
+			item := models.MatchedItem{
+				PartName:      req.PartName,
+				Required:      req.Required,
+				Detected:      detectedQty,
+				Verdict:       models.VerdictOK,
+				Confidence:    confidence.average(),
+				LowConfidence: confidence.low,
+				ModelDetected: modelDetected,
+			}
+			if detectedQty != req.Required {
+				item.Verdict = models.VerdictWithinTolerance
+				item.ToleranceRuleID = rule.ID
+			}
+			// A count the inspector has overridden has already been reviewed.
+			if confidence.low && modelDetected == nil {
+				item.Verdict = models.VerdictNeedsReview
+				needsReview = true
+			}
+			matched = append(matched, item)
+		case detectedQty < req.Required:
+			shortage = append(shortage, models.ShortageItem{
+				PartName:      req.PartName,
+				Required:      req.Required,
+				Detected:      detectedQty,
+				Shortage:      req.Required - detectedQty,
+				Verdict:       models.VerdictShort,
+				Confidence:    confidence.average(),
+				LowConfidence: confidence.low,
+				ModelDetected: modelDetected,
+			})
+		default:
+			surplus = append(surplus, models.SurplusItem{
+				PartName:      req.PartName,
+				Detected:      detectedQty,
+				Required:      req.Required,
+				Surplus:       detectedQty - req.Required,
+				Verdict:       models.VerdictSurplus,
+				Confidence:    confidence.average(),
+				LowConfidence: confidence.low,
+				ModelDetected: modelDetected,
+			})
+		}
+	}
+
+	for _, className := range classNames {
+		if consumed[className] && remaining[className] == 0 {
+			continue
+		}
+		detectedQty, modelDetected := applyOverride(className, remaining[className], rules, &applied)
+		if detectedQty > 0 {
+			var confidence partConfidence
+			confidence.add(className, remaining[className], classConfidence[className], rules.MinConfidence)
+			surplus = append(surplus, models.SurplusItem{
+				PartName:      className,
+				Detected:      detectedQty,
+				Required:      0,
+				Surplus:       detectedQty,
+				Verdict:       models.VerdictUnlisted,
+				Confidence:    confidence.average(),
+				LowConfidence: confidence.low,
+				ModelDetected: modelDetected,
+			})
+		}
+	}
+
+	verdict := models.ComparisonPass
+	if len(shortage) > 0 || len(surplus) > 0 {
+		verdict = models.ComparisonFail
+	} else if needsReview {
+		verdict = models.ComparisonNeedsReview
+	}
+
+	return models.ComparisonResult{
+		Verdict:        verdict,
+		MatchedItems:   matched,
+		ShortageItems:  shortage,
+		SurplusItems:   surplus,
+		UnmappedParts:  unmapped,
+		Detections:     detected,
+		CountOverrides: applied,
+		AlternatesUsed: alternatesUsed,
+	}
+}
@@ -0,0 +1,210 @@
+package detection
+
+import (
+	"reflect"
+	"testing"
+
+	"yolo-server/models"
+)
+
+// partOutcome is what a comparison decided for one part.
+type partOutcome struct {
+	Verdict  string
+	Detected int
+	RuleID   int
+}
+
+func partOutcomes(result models.ComparisonResult) map[string]partOutcome {
+	outcomes := make(map[string]partOutcome)
+	for _, item := range result.MatchedItems {
+		outcomes[item.PartName] = partOutcome{item.Verdict, item.Detected, item.ToleranceRuleID}
+	}
+	for _, item := range result.ShortageItems {
+		outcomes[item.PartName] = partOutcome{item.Verdict, item.Detected, 0}
+	}
+	for _, item := range result.SurplusItems {
+		outcomes[item.PartName] = partOutcome{item.Verdict, item.Detected, 0}
+	}
+	return outcomes
+}
+
+func TestCompareBOMAndDetections(t *testing.T) {
+	tests := []struct {
+		name          string
+		bom           []models.BOMEntry
+		detected      []models.DetectionSummary
+		rules         comparisonRules
+		wantVerdict   string
+		wantParts     map[string]partOutcome
+		wantAlternate []models.AlternateUsage
+	}{
+		{
+			name: "alternate covers a shortfall",
+			bom: []models.BOMEntry{
+				{PartName: "Handle A", Quantity: 4, Alternates: []models.BOMAlternate{{PartName: "Handle B", Priority: 1}}},
+			},
+			detected: []models.DetectionSummary{
+				{ClassName: "Handle A", Quantity: 2, AvgConfidence: 0.9},
+				{ClassName: "Handle B", Quantity: 3, AvgConfidence: 0.9},
+			},
+			wantVerdict: models.ComparisonFail,
+			wantParts: map[string]partOutcome{
+				"Handle A": {models.VerdictOK, 4, 0},
+				"Handle B": {models.VerdictUnlisted, 1, 0},
+			},
+			wantAlternate: []models.AlternateUsage{{PartName: "Handle A", Alternate: "Handle B", ClassName: "Handle B", Quantity: 2}},
+		},
+		{
+			name: "alternate uses the surplus of another line",
+			bom: []models.BOMEntry{
+				{PartName: "Handle A", Quantity: 4, Alternates: []models.BOMAlternate{{PartName: "Handle B", Priority: 1}}},
+				{PartName: "Handle B", Quantity: 2},
+			},
+			detected: []models.DetectionSummary{
+				{ClassName: "Handle A", Quantity: 2, AvgConfidence: 0.9},
+				{ClassName: "Handle B", Quantity: 4, AvgConfidence: 0.9},
+			},
+			wantVerdict: models.ComparisonPass,
+			wantParts: map[string]partOutcome{
+				"Handle A": {models.VerdictOK, 4, 0},
+				"Handle B": {models.VerdictOK, 2, 0},
+			},
+			wantAlternate: []models.AlternateUsage{{PartName: "Handle A", Alternate: "Handle B", ClassName: "Handle B", Quantity: 2}},
+		},
+		{
+			name: "alternate leaves another line its required quantity",
+			bom: []models.BOMEntry{
+				{PartName: "Handle A", Quantity: 4, Alternates: []models.BOMAlternate{{PartName: "Handle B", Priority: 1}}},
+				{PartName: "Handle B", Quantity: 2},
+			},
+			detected: []models.DetectionSummary{
+				{ClassName: "Handle A", Quantity: 2, AvgConfidence: 0.9},
+				{ClassName: "Handle B", Quantity: 3, AvgConfidence: 0.9},
+			},
+			wantVerdict: models.ComparisonFail,
+			wantParts: map[string]partOutcome{
+				"Handle A": {models.VerdictShort, 3, 0},
+				"Handle B": {models.VerdictOK, 2, 0},
+			},
+			wantAlternate: []models.AlternateUsage{{PartName: "Handle A", Alternate: "Handle B", ClassName: "Handle B", Quantity: 1}},
+		},
+		{
+			name: "unused detections go back to their own line",
+			bom: []models.BOMEntry{
+				{PartName: "Handle A", Quantity: 2, Alternates: []models.BOMAlternate{{PartName: "Handle B", Priority: 1}}},
+				{PartName: "Handle B", Quantity: 2},
+			},
+			detected: []models.DetectionSummary{
+				{ClassName: "Handle A", Quantity: 2, AvgConfidence: 0.9},
+				{ClassName: "Handle B", Quantity: 3, AvgConfidence: 0.9},
+			},
+			wantVerdict: models.ComparisonFail,
+			wantParts: map[string]partOutcome{
+				"Handle A": {models.VerdictOK, 2, 0},
+				"Handle B": {models.VerdictSurplus, 3, 0},
+			},
+		},
+		{
+			name: "most specific tolerance rule applies",
+			bom: []models.BOMEntry{
+				{BomCode: "KIT", PartReference: "PR-1", PartName: "Bolt", Quantity: 10},
+				{BomCode: "KIT", PartReference: "PR-2", PartName: "Nut", Quantity: 10},
+				{BomCode: "KIT", PartReference: "PR-3", PartName: "Washer", Quantity: 10},
+			},
+			detected: []models.DetectionSummary{
+				{ClassName: "Bolt", Quantity: 8, AvgConfidence: 0.9},
+				{ClassName: "Nut", Quantity: 9, AvgConfidence: 0.9},
+				{ClassName: "Washer", Quantity: 8, AvgConfidence: 0.9},
+			},
+			rules: comparisonRules{Tolerances: []models.ToleranceRule{
+				{ID: 1, UnderMode: models.ToleranceAbsolute, Under: 5},
+				{ID: 2, BomCode: "KIT", UnderMode: models.ToleranceAbsolute, Under: 1},
+				{ID: 3, PartReference: "PR-1", UnderMode: models.TolerancePercent, Under: 20},
+			}},
+			wantVerdict: models.ComparisonFail,
+			wantParts: map[string]partOutcome{
+				"Bolt":   {models.VerdictWithinTolerance, 8, 3},
+				"Nut":    {models.VerdictWithinTolerance, 9, 2},
+				"Washer": {models.VerdictShort, 8, 0},
+			},
+		},
+		{
+			name:        "low confidence needs review",
+			bom:         []models.BOMEntry{{PartName: "Hinge", Quantity: 2}},
+			detected:    []models.DetectionSummary{{ClassName: "Hinge", Quantity: 2, AvgConfidence: 0.5}},
+			rules:       comparisonRules{MinConfidence: map[string]float64{"Hinge": 0.8}},
+			wantVerdict: models.ComparisonNeedsReview,
+			wantParts:   map[string]partOutcome{"Hinge": {models.VerdictNeedsReview, 2, 0}},
+		},
+		{
+			name:     "override settles a low-confidence count",
+			bom:      []models.BOMEntry{{PartName: "Hinge", Quantity: 2}},
+			detected: []models.DetectionSummary{{ClassName: "Hinge", Quantity: 2, AvgConfidence: 0.5}},
+			rules: comparisonRules{
+				MinConfidence: map[string]float64{"Hinge": 0.8},
+				Overrides:     map[string]models.CountOverride{"Hinge": {OverrideCount: 2}},
+			},
+			wantVerdict: models.ComparisonPass,
+			wantParts:   map[string]partOutcome{"Hinge": {models.VerdictOK, 2, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := compareBOMAndDetections(tt.bom, tt.detected, tt.rules)
+			if result.Verdict != tt.wantVerdict {
+				t.Errorf("verdict = %s, want %s", result.Verdict, tt.wantVerdict)
+			}
+			if got := partOutcomes(result); !reflect.DeepEqual(got, tt.wantParts) {
+				t.Errorf("parts = %+v, want %+v", got, tt.wantParts)
+			}
+			if !reflect.DeepEqual(result.AlternatesUsed, tt.wantAlternate) {
+				t.Errorf("alternates used = %+v, want %+v", result.AlternatesUsed, tt.wantAlternate)
+			}
+		})
+	}
+}
+
+func TestCombineDetections(t *testing.T) {
+	images := []detectedImage{
+		{Detections: []models.DetectionSummary{
+			{ClassName: "Bolt", Quantity: 1, AvgConfidence: 0.9},
+			{ClassName: "Bolt", Quantity: 1, AvgConfidence: 0.7},
+			{ClassName: "Nut", Quantity: 1, AvgConfidence: 0.8},
+		}},
+		{Detections: []models.DetectionSummary{
+			{ClassName: "Bolt", Quantity: 1, AvgConfidence: 0.6},
+			{ClassName: "Washer", Quantity: 3, AvgConfidence: 0.75},
+		}},
+	}
+
+	tests := []struct {
+		strategy string
+		want     []models.DetectionSummary
+	}{
+		{
+			strategy: models.CountStrategySum,
+			want: []models.DetectionSummary{
+				{ClassName: "Bolt", Quantity: 3, AvgConfidence: 0.7333},
+				{ClassName: "Nut", Quantity: 1, AvgConfidence: 0.8},
+				{ClassName: "Washer", Quantity: 3, AvgConfidence: 0.75},
+			},
+		},
+		{
+			strategy: models.CountStrategyMax,
+			want: []models.DetectionSummary{
+				{ClassName: "Bolt", Quantity: 2, AvgConfidence: 0.8},
+				{ClassName: "Nut", Quantity: 1, AvgConfidence: 0.8},
+				{ClassName: "Washer", Quantity: 3, AvgConfidence: 0.75},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			if got := combineDetections(images, tt.strategy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("combineDetections(%s) = %+v, want %+v", tt.strategy, got, tt.want)
+			}
+		})
+	}
+}
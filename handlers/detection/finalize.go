@@ -0,0 +1,161 @@
+package detection
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FinalizeInspection turns the stored comparison into action items and locks the
+// inspection, in one transaction. The items record the run they come from.
+func FinalizeInspection(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("bomCode")
+
+	var req models.FinalizeInspectionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var resultJSON []byte
+	var finalized bool
+	var runID sql.NullInt64
+	err = tx.QueryRow(
+		"SELECT comparison_result_json, COALESCE(is_finalized, FALSE), latest_run_id FROM detection_results WHERE bom_code = $1 FOR UPDATE",
+		bomCode,
+	).Scan(&resultJSON, &finalized, &runID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No detection result found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection result: " + err.Error()})
+		return
+	}
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": "Inspection is already finalized"})
+		return
+	}
+
+	var result models.ComparisonResult
+	if err := json.Unmarshal(resultJSON, &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse comparison result JSON: " + err.Error()})
+		return
+	}
+
+	// Items of an earlier finalization that was reopened are replaced unless someone
+	// picked them up. Items reported through POST /api/action-items have no run.
+	if _, err := tx.Exec("DELETE FROM actionable_items WHERE bom_code = $1 AND status = 'BARU_MASUK' AND run_id IS NOT NULL", bomCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear previous action items"})
+		return
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO actionable_items (bom_code, part_name, item_type, quantity_diff, run_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, updated_at
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare statement"})
+		return
+	}
+	defer stmt.Close()
+
+	items := []models.ActionableItem{}
+	for _, item := range actionItemsFromResult(bomCode, result) {
+		if err := stmt.QueryRow(item.BomCode, item.PartName, item.ItemType, item.QuantityDiff, runID).Scan(
+			&item.ID, &item.Status, &item.CreatedAt, &item.UpdatedAt,
+		); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert item"})
+			return
+		}
+		items = append(items, item)
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE detection_results SET is_finalized = TRUE, finalized_at = NOW(), finalized_by = NULLIF($1, '') WHERE bom_code = $2",
+		req.FinalizedBy, bomCode,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finalize detection result"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Inspection finalized", "items": items})
+}
+
+// actionItemsFromResult derives action items from a comparison: every shortage, and
+// every detected part that is not in the BOM at all.
+func actionItemsFromResult(bomCode string, result models.ComparisonResult) []models.ActionableItem {
+	var items []models.ActionableItem
+	for _, s := range result.ShortageItems {
+		items = append(items, models.ActionableItem{
+			BomCode:      bomCode,
+			PartName:     s.PartName,
+			ItemType:     "SHORTAGE",
+			QuantityDiff: s.Shortage,
+		})
+	}
+	for _, s := range result.SurplusItems {
+		if s.Required == 0 {
+			items = append(items, models.ActionableItem{
+				BomCode:      bomCode,
+				PartName:     s.PartName,
+				ItemType:     "UNLISTED",
+				QuantityDiff: s.Surplus,
+			})
+		}
+	}
+	return items
+}
+
+// ReopenInspection unlocks a finalized inspection. It requires a supervisor and a
+// reason, which are kept on the detection result.
+func ReopenInspection(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("bomCode")
+
+	var req models.ReopenInspectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Supervisor and reason are required"})
+		return
+	}
+
+	result, err := db.Exec(`
+		UPDATE detection_results
+		SET is_finalized = FALSE, reopened_at = NOW(), reopened_by = $1, reopen_reason = $2
+		WHERE bom_code = $3 AND is_finalized
+	`, req.Supervisor, req.Reason, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reopen inspection"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check affected rows"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No finalized inspection found for this BOM"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Inspection reopened"})
+}
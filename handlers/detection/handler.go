@@ -1,222 +1,347 @@
 package detection
 
 import (
-    "bytes"
+    "context"
     "database/sql"
-    "encoding/base64" 
     "encoding/json"
+    "errors"
     "fmt"
     "io"
-    "io/ioutil" 
     "log"
     "mime/multipart"
     "net/http"
-    "os"
     "path/filepath"
+    "strconv"
+    "strings"
     "time"
 
+    "yolo-server/detector"
+    "yolo-server/handlers/alias"
+    "yolo-server/handlers/bom"
+    "yolo-server/handlers/part"
     "yolo-server/models"
+    "yolo-server/storage"
 
     "github.com/gin-gonic/gin"
 )
 
-func saveUploadedFile(fileHeader *multipart.FileHeader) (string, error) {
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		os.Mkdir(uploadDir, 0755)
+// saveUploadedFile stores an inspection image in blob storage and returns its key.
+func saveUploadedFile(ctx context.Context, blobs *storage.Blobs, bomCode, fileName string, data []byte, contentType string) (string, error) {
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
 	}
+	stem := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	key := fmt.Sprintf("detections/%s/%d-%s%s", storage.KeySegment(bomCode), time.Now().UnixNano(), storage.KeySegment(stem), storage.ExtensionFor(contentType))
 
-	src, err := fileHeader.Open()
-	if err != nil {
+	if err := blobs.Put(ctx, key, data, contentType); err != nil {
 		return "", err
 	}
-	defer src.Close()
+	return key, nil
+}
+
+func readFileHeader(fileHeader *multipart.FileHeader) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// defaultModelName is recorded on a run when the predictor's bundled weights are used.
+const defaultModelName = "best.pt"
+
+// errPredictorUnavailable marks failures of the detector, as opposed to
+// failures of our own database.
+var errPredictorUnavailable = errors.New("predictor unavailable")
 
-	uniqueFileName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(fileHeader.Filename))
-	dstPath := filepath.Join(uploadDir, uniqueFileName)
+// errInspectionFinalized is returned when a finalized inspection would be changed.
+var errInspectionFinalized = errors.New("inspection is finalized; a supervisor must reopen it first")
 
-	dst, err := os.Create(dstPath)
+// errBOMNotFound is returned when a detection names a BOM code without a header.
+var errBOMNotFound = errors.New("BOM tidak ditemukan")
+
+// requireBOM responds with 404 and returns false when the BOM does not exist.
+func requireBOM(c *gin.Context, db *sql.DB, bomCode string) bool {
+	exists, err := bom.Exists(db, bomCode)
 	if err != nil {
-		return "", err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa BOM"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM " + bomCode + " tidak ditemukan"})
+		return false
 	}
-	defer dst.Close()
+	return true
+}
 
-	if _, err = io.Copy(dst, src); err != nil {
-		return "", err
+// detectionInput is everything a detection needs, so it can run inside a request
+// or later from the job queue.
+type detectionInput struct {
+	BomCode  string
+	Images   []inputImage
+	View     string
+	Strategy string
+	Params   models.DetectionParams
+	// Model holds custom weights; queued jobs keep them in blob storage under ModelKey.
+	Model    []byte
+	ModelKey string
+}
+
+// parseBOMView validates the ?view= query parameter, defaulting to the top-level lines.
+func parseBOMView(view string) (string, error) {
+	switch view {
+	case "", models.BOMViewTop:
+		return models.BOMViewTop, nil
+	case models.BOMViewExploded:
+		return models.BOMViewExploded, nil
 	}
+	return "", fmt.Errorf("view tidak valid: %s (gunakan top atau exploded)", view)
+}
 
-	return "/uploads/" + uniqueFileName, nil
+// comparisonLines returns the BOM lines a detection is compared with, exploding
+// sub-assemblies into leaf parts for the exploded view. Lines that refer to the
+// parts catalog take the catalog name.
+func comparisonLines(db *sql.DB, catalog *part.Catalog, lines []models.BOMEntry, view string) ([]models.BOMEntry, error) {
+	if view == models.BOMViewExploded {
+		var err error
+		lines, err = bom.ExplodeLines(db, lines, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return catalog.Canonicalize(lines), nil
 }
 
-func convertFileHeaderToDataURL(fileHeader *multipart.FileHeader) (string, error) {
-    file, err := fileHeader.Open()
-    if err != nil {
-        return "", err
-    }
-    defer file.Close()
-
-    bytes, err := ioutil.ReadAll(file)
-    if err != nil {
-        return "", err
-    }
-
-    base64Str := base64.StdEncoding.EncodeToString(bytes)
-    
-    contentType := http.DetectContentType(bytes)
-    
-    return fmt.Sprintf("data:%s;base64,%s", contentType, base64Str), nil
+// loadAliases returns the configured class aliases followed by the detector
+// classes of the parts catalog, so explicit aliases are tried first.
+func loadAliases(db *sql.DB, catalog *part.Catalog) ([]models.PartClassAlias, error) {
+	aliases, err := alias.LoadAll(db)
+	if err != nil {
+		return nil, err
+	}
+	return append(aliases, catalog.Aliases()...), nil
 }
 
-func HandleDetectAndCompare(c *gin.Context, db *sql.DB, pythonApiUrl string) {
-	bomCode := c.Param("bomCode")
-	imageFileHeader, err := c.FormFile("file")
+// runDetection sends the images to the predictor, compares their combined counts
+// with the BOM and stores them as a new run. The original images must already be
+// in blob storage.
+func runDetection(ctx context.Context, db *sql.DB, det detector.Detector, blobs *storage.Blobs, in detectionInput) (*models.ComparisonResult, error) {
+	exists, err := bom.Exists(db, in.BomCode)
+	if err != nil {
+		return nil, fmt.Errorf("gagal memeriksa BOM: %w", err)
+	}
+	if !exists {
+		return nil, errBOMNotFound
+	}
+
+	finalized, err := bom.IsFinalized(db, in.BomCode)
 	if err != nil {
+		return nil, fmt.Errorf("gagal memeriksa status finalisasi: %w", err)
+	}
+	if finalized {
+		return nil, errInspectionFinalized
+	}
+
+	images, err := detectImages(ctx, db, det, blobs, in.BomCode, in.Images, in.Params, in.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	comparisonResult, err := compareImages(db, in.BomCode, in.View, in.Strategy, in.Params.ModelID, images)
+	if err != nil {
+		return nil, err
+	}
+	params := in.Params
+	comparisonResult.DetectionParams = &params
+	modelUsed := defaultModelName
+	if params.Model != "" {
+		modelUsed = params.Model
+	}
+	if _, err := saveDetectionRun(db, in.BomCode, modelUsed, images, &comparisonResult); err != nil {
+		return nil, fmt.Errorf("gagal menyimpan hasil deteksi: %w", err)
+	}
+	comparisonResult.OriginalImage = blobs.URL(images[0].OriginalKey)
+	comparisonResult.AnnotatedImage = blobs.URL(images[0].AnnotatedKey)
+	comparisonResult.Images = imageResults(blobs, images)
+	return &comparisonResult, nil
+}
+
+func HandleDetectAndCompare(c *gin.Context, db *sql.DB, det detector.Detector, blobs *storage.Blobs, jobs *JobQueue) {
+	bomCode := c.Param("bomCode")
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File gambar tidak ditemukan"})
 		return
 	}
+	view, err := parseBOMView(c.Query("view"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	strategy, err := parseCountStrategy(formOrQuery(c, "strategy"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params, err := parseDetectionParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	modelName, model, err := readModelFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params.Model = modelName
 
-	originalImageBase64, err := convertFileHeaderToDataURL(imageFileHeader)
-    if err != nil {
-        log.Printf("Gagal konversi gambar asli ke base64: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses gambar asli"})
-        return
-    }
+	finalized, err := bom.IsFinalized(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa status finalisasi"})
+		return
+	}
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": errInspectionFinalized.Error()})
+		return
+	}
 
-	pythonResp, err := callPythonAPI(c, imageFileHeader, pythonApiUrl)
+	params, err = resolveParams(db, bomCode, params)
 	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Gagal mendapatkan prediksi dari Python API", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menentukan parameter dan model deteksi"})
 		return
 	}
 
-	bomItems, err := getBOMItemsByCode(db, bomCode)
+	in := detectionInput{BomCode: bomCode, View: view, Strategy: strategy, Params: params, Model: model}
+	in.Images, err = storeImageFiles(c.Request.Context(), blobs, bomCode, form.File["file"])
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data BOM"})
+		log.Printf("Gagal menyimpan gambar asli: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan gambar asli"})
 		return
 	}
 
-	comparisonResult := compareBOMAndDetections(bomItems, pythonResp.Summary)
-	comparisonResult.AnnotatedImage = pythonResp.AnnotatedImage
-	comparisonResult.OriginalImage = originalImageBase64
-	comparisonJSON, _ := json.Marshal(comparisonResult)
-	upsert := `
-		INSERT INTO detection_results (bom_code, original_image, annotated_image, comparison_result_json)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (bom_code) DO UPDATE
-		SET original_image = EXCLUDED.original_image,
-			annotated_image = EXCLUDED.annotated_image,
-			comparison_result_json = EXCLUDED.comparison_result_json,
-			updated_at = NOW();
-	`
-	db.Exec(upsert, bomCode, comparisonResult.OriginalImage, comparisonResult.AnnotatedImage, comparisonJSON)
+	if c.Query("async") == "true" {
+		if len(model) > 0 {
+			in.ModelKey, err = saveModelFile(c.Request.Context(), blobs, bomCode, modelName, model)
+			if err != nil {
+				log.Printf("Gagal menyimpan file model: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan file model"})
+				return
+			}
+		}
+		jobID, err := jobs.Enqueue(in)
+		if err != nil {
+			log.Printf("Gagal membuat job deteksi untuk %s: %v", bomCode, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat job deteksi"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"jobId": jobID, "status": models.JobStatusQueued})
+		return
+	}
+
+	comparisonResult, err := runDetection(c.Request.Context(), db, det, blobs, in)
+	if err != nil {
+		respondDetectionError(c, bomCode, err)
+		return
+	}
 	c.JSON(http.StatusOK, comparisonResult)
 }
 
-func callPythonAPI(c *gin.Context, file *multipart.FileHeader, pythonApiUrl string) (*models.PythonResponse, error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	image, _ := file.Open()
-	part, _ := writer.CreateFormFile("file", filepath.Base(file.Filename))
-	io.Copy(part, image)
-	image.Close()
-
-	writer.Close()
+// respondDetectionError maps a failed detection to its response.
+func respondDetectionError(c *gin.Context, bomCode string, err error) {
+	switch {
+	case errors.Is(err, errBOMNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errInspectionFinalized):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errPredictorUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Gagal mendapatkan prediksi dari Python API", "details": err.Error()})
+	default:
+		log.Printf("Deteksi untuk %s gagal: %v", bomCode, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses hasil deteksi"})
+	}
+}
 
-	req, _ := http.NewRequest("POST", pythonApiUrl, body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+// saveDetectionRun appends the run to detection_runs and points
+// detection_results (the latest inspection per BOM) at it. Images are stored
+// by blob key; the comparison JSON never carries image data. The run and the
+// latest result show the first image, detection_run_images keeps them all.
+// Nothing is stored and errInspectionFinalized is returned when the inspection
+// was finalized in the meantime.
+func saveDetectionRun(db *sql.DB, bomCode, modelUsed string, images []detectedImage, result *models.ComparisonResult) (int, error) {
+	originalKey, annotatedKey := images[0].OriginalKey, images[0].AnnotatedKey
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	client := &http.Client{Timeout: 2 * time.Minute}
-	resp, err := client.Do(req)
+	var runID int
+	insertRun := `
+		INSERT INTO detection_runs (bom_code, original_image, annotated_image, comparison_result_json, model_used, bom_revision)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	result.OriginalImage, result.AnnotatedImage, result.Images = "", "", nil
+	comparisonJSON, err := json.Marshal(result)
 	if err != nil {
-		return nil, fmt.Errorf("python API tidak merespon: %w", err)
+		return 0, err
+	}
+	if err := tx.QueryRow(insertRun, bomCode, originalKey, annotatedKey, comparisonJSON, modelUsed, result.BomRevision).Scan(&runID); err != nil {
+		return 0, err
 	}
-	defer resp.Body.Close()
 
-	var result models.PythonResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("gagal decode response dari Python: %w", err)
+	if err := saveRunImages(tx, runID, images); err != nil {
+		return 0, err
 	}
-	return &result, nil
-}
 
-func getBOMItemsByCode(db *sql.DB, bomCode string) (map[string]int, error) {
-	rows, err := db.Query("SELECT part_name, quantity FROM boms WHERE bom_code = $1", bomCode)
+	result.RunID = runID
+	comparisonJSON, err = json.Marshal(result)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
-
-	items := make(map[string]int)
-	for rows.Next() {
-		var name string
-		var qty int
-		rows.Scan(&name, &qty)
-		items[name] = qty
+	upsert := `
+		INSERT INTO detection_results (bom_code, original_image, annotated_image, comparison_result_json, latest_run_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (bom_code) DO UPDATE
+		SET original_image = EXCLUDED.original_image,
+			annotated_image = EXCLUDED.annotated_image,
+			comparison_result_json = EXCLUDED.comparison_result_json,
+			latest_run_id = EXCLUDED.latest_run_id,
+			updated_at = NOW()
+		WHERE NOT COALESCE(detection_results.is_finalized, FALSE);
+	`
+	upserted, err := tx.Exec(upsert, bomCode, originalKey, annotatedKey, comparisonJSON, runID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := upserted.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errInspectionFinalized
 	}
-	return items, nil
-}
 
-func compareBOMAndDetections(bomItems map[string]int, detected []models.DetectionSummary) models.ComparisonResult {
-    detectedMap := make(map[string]int)
-    for _, s := range detected {
-        detectedMap[s.ClassName] = s.Quantity
-    }
-
-    var shortage []models.ShortageItem
-    var surplus []models.SurplusItem 
-
-    for partName, requiredQty := range bomItems {
-        detectedQty, found := detectedMap[partName]
-
-        if !found {
-            shortage = append(shortage, models.ShortageItem{
-                PartName: partName,
-                Required: requiredQty,
-                Detected: 0,
-                Shortage: requiredQty,
-            })
-        } else if detectedQty < requiredQty {
-            shortage = append(shortage, models.ShortageItem{
-                PartName: partName,
-                Required: requiredQty,
-                Detected: detectedQty,
-                Shortage: requiredQty - detectedQty,
-            })
-        } else if detectedQty > requiredQty {
-            surplus = append(surplus, models.SurplusItem{
-                PartName: partName,
-                Detected: detectedQty,
-                Required: requiredQty,
-                Surplus:  detectedQty - requiredQty,
-            })
-        }
-    }
-
-    for partName, detectedQty := range detectedMap {
-        _, foundInBom := bomItems[partName]
-        if !foundInBom {
-            surplus = append(surplus, models.SurplusItem{
-                PartName: partName,
-                Detected: detectedQty,
-                Required: 0, 
-                Surplus:  detectedQty,
-            })
-        }
-    }
-
-    return models.ComparisonResult{
-        ShortageItems: shortage,
-        SurplusItems:  surplus,
-    }
+	return runID, tx.Commit()
 }
 
-func GetDetectionResult(c *gin.Context, db *sql.DB) {
+func GetDetectionResult(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
 	bomCode := c.Param("bomCode")
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
 	var resultJSON string
 	var isFinalized sql.NullBool
-    
-	query := "SELECT comparison_result_json, is_finalized FROM detection_results WHERE bom_code=$1"
-	err := db.QueryRow(query, bomCode).Scan(&resultJSON, &isFinalized)
+	var originalKey, annotatedKey string
+	var runID sql.NullInt64
+
+	query := "SELECT comparison_result_json, is_finalized, original_image, annotated_image, latest_run_id FROM detection_results WHERE bom_code=$1"
+	err := db.QueryRow(query, bomCode).Scan(&resultJSON, &isFinalized, &originalKey, &annotatedKey, &runID)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No detection result found"})
@@ -234,6 +359,14 @@ func GetDetectionResult(c *gin.Context, db *sql.DB) {
 	}
 
 	result.IsFinalized = isFinalized.Valid && isFinalized.Bool
+	result.OriginalImage = blobs.URL(originalKey)
+	result.AnnotatedImage = blobs.URL(annotatedKey)
+	if runID.Valid {
+		if result.Images, err = loadRunImages(db, blobs, int(runID.Int64)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection images: " + err.Error()})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, result)
 }
@@ -241,6 +374,16 @@ func GetDetectionResult(c *gin.Context, db *sql.DB) {
 func ResetDetectionResult(c *gin.Context, db *sql.DB) {
 	bomCode := c.Param("bomCode")
 
+	finalized, err := bom.IsFinalized(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check finalization status"})
+		return
+	}
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": errInspectionFinalized.Error()})
+		return
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
@@ -253,3 +396,85 @@ func ResetDetectionResult(c *gin.Context, db *sql.DB) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Detection result reset successfully"})
 }
+
+
+func GetDetectionRuns(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("bomCode")
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
+
+	query := `
+		SELECT id, bom_code, model_used, bom_revision, created_at,
+			COALESCE(jsonb_array_length(NULLIF(comparison_result_json->'shortageItems', 'null')), 0),
+			COALESCE(jsonb_array_length(NULLIF(comparison_result_json->'surplusItems', 'null')), 0)
+		FROM detection_runs
+		WHERE bom_code = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := db.Query(query, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection runs: " + err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	runs := []models.DetectionRun{}
+	for rows.Next() {
+		var run models.DetectionRun
+		var bomRevision sql.NullInt64
+		if err := rows.Scan(&run.ID, &run.BomCode, &run.ModelUsed, &bomRevision, &run.CreatedAt, &run.ShortageCount, &run.SurplusCount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan detection run"})
+			return
+		}
+		if bomRevision.Valid {
+			rev := int(bomRevision.Int64)
+			run.BomRevision = &rev
+		}
+		runs = append(runs, run)
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+func GetDetectionRun(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	bomCode := c.Param("bomCode")
+	runID, err := strconv.Atoi(c.Param("runId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	var run models.DetectionRun
+	var resultJSON string
+	var originalKey, annotatedKey string
+	query := "SELECT id, bom_code, model_used, created_at, comparison_result_json, original_image, annotated_image FROM detection_runs WHERE id = $1 AND bom_code = $2"
+	err = db.QueryRow(query, runID, bomCode).Scan(&run.ID, &run.BomCode, &run.ModelUsed, &run.CreatedAt, &resultJSON, &originalKey, &annotatedKey)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detection run not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection run: " + err.Error()})
+		return
+	}
+
+	var result models.ComparisonResult
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse comparison result JSON: " + err.Error()})
+		return
+	}
+	result.RunID = run.ID
+	result.OriginalImage = blobs.URL(originalKey)
+	result.AnnotatedImage = blobs.URL(annotatedKey)
+	if result.Images, err = loadRunImages(db, blobs, run.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection images: " + err.Error()})
+		return
+	}
+	run.ShortageCount = len(result.ShortageItems)
+	run.SurplusCount = len(result.SurplusItems)
+	run.BomRevision = result.BomRevision
+	run.Result = &result
+
+	c.JSON(http.StatusOK, run)
+}
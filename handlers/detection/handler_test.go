@@ -0,0 +1,249 @@
+package detection
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"yolo-server/detector"
+	"yolo-server/handlers/image"
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/lib/pq"
+)
+
+// testDB connects to the database in TEST_DATABASE_URL, which must have the
+// schema of init.sql, and skips the test when it is not set.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testBOM creates a BOM with the given part quantities and removes it, with its
+// detection runs, when the test ends.
+func testBOM(t *testing.T, db *sql.DB, parts map[string]int) string {
+	t.Helper()
+	bomCode := fmt.Sprintf("TEST-%d", time.Now().UnixNano())
+	if _, err := db.Exec("INSERT INTO bom_headers (bom_code) VALUES ($1)", bomCode); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM detection_results WHERE bom_code = $1", bomCode)
+		db.Exec("DELETE FROM detection_runs WHERE bom_code = $1", bomCode)
+		db.Exec("DELETE FROM boms WHERE bom_code = $1", bomCode)
+		db.Exec("DELETE FROM bom_headers WHERE bom_code = $1", bomCode)
+	})
+	i := 0
+	for name, quantity := range parts {
+		i++
+		if _, err := db.Exec("INSERT INTO boms (bom_code, part_reference, part_name, quantity) VALUES ($1, $2, $3, $4)", bomCode, fmt.Sprintf("REF-%d", i), name, quantity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bomCode
+}
+
+// writeFixtures writes scripted predictor responses for the fake detector.
+func writeFixtures(t *testing.T, fixtures map[string]models.PythonResponse) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, response := range fixtures {
+		data, err := json.Marshal(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testBlobs(t *testing.T) *storage.Blobs {
+	t.Helper()
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &storage.Blobs{
+		Store:  store,
+		Signer: &storage.URLSigner{BasePath: "/api/images", Secret: []byte("test"), TTL: time.Minute},
+	}
+}
+
+func TestSignedImageURLRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	blobs := testBlobs(t)
+
+	key, err := saveUploadedFile(context.Background(), blobs, "KIT 01/A", "my photo.jpg", []byte("\xff\xd8\xff\xe0 not really a jpeg"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("saveUploadedFile: %v", err)
+	}
+	if strings.ContainsAny(key, " %") {
+		t.Errorf("key %q needs escaping", key)
+	}
+
+	// Keys stored before they were sanitized may still hold a space.
+	legacyKey := "detections/KIT01/1-old photo.jpg"
+	if err := blobs.Put(context.Background(), legacyKey, []byte("\xff\xd8\xff\xe0 not really a jpeg"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/api/images/*key", func(c *gin.Context) { image.ServeImage(c, blobs) })
+	for _, k := range []string{key, legacyKey} {
+		link := blobs.URL(k)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, link, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s, want 200", link, w.Code, w.Body)
+		}
+		if !strings.HasSuffix(w.Body.String(), "not really a jpeg") {
+			t.Errorf("GET %s served %q, want the stored image", link, w.Body)
+		}
+	}
+}
+
+func TestHandleDetectAndCompare(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testDB(t)
+	blobs := testBlobs(t)
+	bomCode := testBOM(t, db, map[string]int{"Drawer Stopper": 2, "Roda Drawer": 4})
+	det, err := detector.NewFakeDetector(writeFixtures(t, map[string]models.PythonResponse{
+		"left": {Summary: []models.DetectionSummary{
+			{ClassName: "Drawer Stopper", Quantity: 2, AvgConfidence: 0.9},
+			{ClassName: "Roda Drawer", Quantity: 1, AvgConfidence: 0.8},
+		}},
+		"right": {Summary: []models.DetectionSummary{
+			{ClassName: "Roda Drawer", Quantity: 3, AvgConfidence: 0.8},
+		}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, name := range []string{"left.jpg", "right.jpg"} {
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("\xff\xd8\xff\xe0 " + name))
+	}
+	writer.Close()
+
+	r := gin.New()
+	r.POST("/detect/:bomCode", func(c *gin.Context) { HandleDetectAndCompare(c, db, det, blobs, nil) })
+	req := httptest.NewRequest(http.MethodPost, "/detect/"+bomCode+"?strategy=sum", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /detect/%s = %d %s, want 200", bomCode, w.Code, w.Body)
+	}
+
+	var result models.ComparisonResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != models.ComparisonPass {
+		t.Errorf("verdict = %s, want %s: %s", result.Verdict, models.ComparisonPass, w.Body)
+	}
+	if len(result.Images) != 2 {
+		t.Errorf("got %d images, want 2", len(result.Images))
+	}
+	var stored int
+	if err := db.QueryRow("SELECT latest_run_id FROM detection_results WHERE bom_code = $1", bomCode).Scan(&stored); err != nil || stored != result.RunID {
+		t.Errorf("latest run = %d (%v), want %d", stored, err, result.RunID)
+	}
+}
+
+// TestDetectImagesWithFakeDetector runs the detection pipeline without a database:
+// the fake detector's counts of two photos are combined and compared with a BOM.
+func TestDetectImagesWithFakeDetector(t *testing.T) {
+	ctx := context.Background()
+	blobs := testBlobs(t)
+	det, err := detector.NewFakeDetector(writeFixtures(t, map[string]models.PythonResponse{
+		"left": {Summary: []models.DetectionSummary{
+			{ClassName: "Drawer Stopper", Quantity: 2, AvgConfidence: 0.9},
+			{ClassName: "Roda Drawer", Quantity: 1, AvgConfidence: 0.8},
+		}},
+		"right": {Summary: []models.DetectionSummary{
+			{ClassName: "Roda Drawer", Quantity: 3, AvgConfidence: 0.8},
+		}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inputs []inputImage
+	for _, name := range []string{"left.jpg", "right.jpg"} {
+		data := []byte("\xff\xd8\xff\xe0 " + name)
+		key, err := saveUploadedFile(ctx, blobs, "KIT-01", name, data, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, inputImage{FileName: name, Key: key, Data: data})
+	}
+
+	images, err := detectImages(ctx, nil, det, blobs, "KIT-01", inputs, models.DetectionParams{}, nil)
+	if err != nil {
+		t.Fatalf("detectImages: %v", err)
+	}
+	for _, img := range images {
+		if _, _, err := blobs.Get(ctx, img.AnnotatedKey); err != nil {
+			t.Errorf("annotated image of %s not stored: %v", img.FileName, err)
+		}
+	}
+
+	bomLines := []models.BOMEntry{
+		{BomCode: "KIT-01", PartReference: "REF-1", PartName: "Drawer Stopper", Quantity: 2},
+		{BomCode: "KIT-01", PartReference: "REF-2", PartName: "Roda Drawer", Quantity: 4},
+	}
+	for _, tt := range []struct {
+		strategy string
+		want     string
+	}{
+		{models.CountStrategySum, models.ComparisonPass},
+		{models.CountStrategyMax, models.ComparisonFail},
+	} {
+		result := compareBOMAndDetections(bomLines, combineDetections(images, tt.strategy), comparisonRules{})
+		if result.Verdict != tt.want {
+			t.Errorf("verdict with %s = %s, want %s", tt.strategy, result.Verdict, tt.want)
+		}
+	}
+
+	broken, err := detector.NewFakeDetector(writeFixtures(t, map[string]models.PythonResponse{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := detectImages(ctx, nil, broken, blobs, "KIT-01", inputs, models.DetectionParams{}, nil); !errors.Is(err, errPredictorUnavailable) {
+		t.Errorf("detectImages without fixtures = %v, want errPredictorUnavailable", err)
+	}
+}
@@ -0,0 +1,285 @@
+package detection
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"path/filepath"
+	"time"
+
+	"yolo-server/detector"
+	"yolo-server/handlers/bom"
+	"yolo-server/handlers/part"
+	"yolo-server/handlers/threshold"
+	"yolo-server/handlers/tolerance"
+	"yolo-server/models"
+	"yolo-server/storage"
+)
+
+// inputImage is one photo of an inspection, stored in blob storage under Key.
+// Data is only held while the photo is sent to the predictor.
+type inputImage struct {
+	FileName string `json:"fileName"`
+	Key      string `json:"key"`
+	Data     []byte `json:"-"`
+}
+
+// detectedImage is a photo with the predictor output for it.
+type detectedImage struct {
+	FileName     string
+	OriginalKey  string
+	AnnotatedKey string
+	Detections   []models.DetectionSummary
+}
+
+// parseCountStrategy validates the strategy parameter, defaulting to sum.
+func parseCountStrategy(strategy string) (string, error) {
+	switch strategy {
+	case "", models.CountStrategySum:
+		return models.CountStrategySum, nil
+	case models.CountStrategyMax:
+		return models.CountStrategyMax, nil
+	}
+	return "", fmt.Errorf("strategy tidak valid: %s (gunakan sum atau max)", strategy)
+}
+
+// storeImageFiles reads uploaded photos and puts them in blob storage.
+func storeImageFiles(ctx context.Context, blobs *storage.Blobs, bomCode string, fileHeaders []*multipart.FileHeader) ([]inputImage, error) {
+	images := make([]inputImage, 0, len(fileHeaders))
+	for _, fileHeader := range fileHeaders {
+		img := inputImage{FileName: filepath.Base(fileHeader.Filename)}
+		var err error
+		if img.Data, err = readFileHeader(fileHeader); err != nil {
+			return nil, fmt.Errorf("gagal membaca %s: %w", img.FileName, err)
+		}
+		if img.Key, err = saveUploadedFile(ctx, blobs, bomCode, img.FileName, img.Data, ""); err != nil {
+			return nil, fmt.Errorf("gagal menyimpan %s: %w", img.FileName, err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// detectImages sends every photo to the predictor and stores the annotated images.
+// Uploaded weights in model are named by params.Model; without them the weights
+// of a registry model are used when params.ModelID is set. The weights are keyed
+// by their hash, so the predictor receives them only with the first photo.
+func detectImages(ctx context.Context, db *sql.DB, det detector.Detector, blobs *storage.Blobs, bomCode string, images []inputImage, params models.DetectionParams, model []byte) ([]detectedImage, error) {
+	modelFile := params.Model
+	if model == nil && params.ModelID != nil {
+		var err error
+		model, modelFile, err = loadModelWeights(ctx, db, blobs, *params.ModelID)
+		if err != nil {
+			return nil, fmt.Errorf("gagal mengambil bobot model %s: %w", params.Model, err)
+		}
+	}
+	var modelKey string
+	if len(model) > 0 {
+		sum := sha256.Sum256(model)
+		modelKey = hex.EncodeToString(sum[:])
+	}
+
+	detectedImages := make([]detectedImage, 0, len(images))
+	for _, img := range images {
+		detected, err := det.Detect(ctx, detector.Request{FileName: img.FileName, Image: img.Data, Params: params, Model: model, ModelFile: modelFile, ModelKey: modelKey})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errPredictorUnavailable, err)
+		}
+
+		d := detectedImage{FileName: img.FileName, OriginalKey: img.Key, Detections: detected.Summary}
+		if detected.AnnotatedImage != "" {
+			annotated, contentType, err := storage.DecodeDataURL(detected.AnnotatedImage)
+			if err != nil {
+				return nil, fmt.Errorf("gambar anotasi tidak valid: %w", err)
+			}
+			d.AnnotatedKey, err = saveUploadedFile(ctx, blobs, bomCode, img.FileName+"-annotated", annotated, contentType)
+			if err != nil {
+				return nil, fmt.Errorf("gagal menyimpan gambar anotasi: %w", err)
+			}
+		}
+		detectedImages = append(detectedImages, d)
+	}
+	return detectedImages, nil
+}
+
+// combineDetections merges the per-class counts of several images. With sum the
+// confidence is averaged over all counted detections; with max it is the one of
+// the image that gave the highest count.
+func combineDetections(images []detectedImage, strategy string) []models.DetectionSummary {
+	var combined []models.DetectionSummary
+	index := make(map[string]int)
+	for _, img := range images {
+		// An image may list a class more than once; count it as one view.
+		perImage := make(map[string]*models.DetectionSummary)
+		var classNames []string
+		for _, s := range img.Detections {
+			if t, ok := perImage[s.ClassName]; ok {
+				t.AvgConfidence = (t.AvgConfidence*float64(t.Quantity) + s.AvgConfidence*float64(s.Quantity)) / float64(max(t.Quantity+s.Quantity, 1))
+				t.Quantity += s.Quantity
+				continue
+			}
+			perImage[s.ClassName] = &s
+			classNames = append(classNames, s.ClassName)
+		}
+
+		for _, className := range classNames {
+			s := *perImage[className]
+			i, ok := index[className]
+			if !ok {
+				index[className] = len(combined)
+				combined = append(combined, s)
+				continue
+			}
+			total := &combined[i]
+			switch strategy {
+			case models.CountStrategyMax:
+				if s.Quantity > total.Quantity || (s.Quantity == total.Quantity && s.AvgConfidence > total.AvgConfidence) {
+					*total = s
+				}
+			default:
+				if quantity := total.Quantity + s.Quantity; quantity > 0 {
+					total.AvgConfidence = (total.AvgConfidence*float64(total.Quantity) + s.AvgConfidence*float64(s.Quantity)) / float64(quantity)
+				}
+				total.Quantity += s.Quantity
+			}
+		}
+	}
+	for i := range combined {
+		combined[i].AvgConfidence = math.Round(combined[i].AvgConfidence*10000) / 10000
+	}
+	return combined
+}
+
+// compareImages compares the combined counts of the images with the BOM as it
+// is in effect now. When the detection used the registry model modelID, the parts
+// that model cannot detect are listed in the result.
+func compareImages(db *sql.DB, bomCode, view, strategy string, modelID *int, images []detectedImage) (models.ComparisonResult, error) {
+	var result models.ComparisonResult
+	bomItems, bomRevision, err := bom.EffectiveLines(db, bomCode, time.Now())
+	if err != nil {
+		return result, fmt.Errorf("gagal mengambil data BOM: %w", err)
+	}
+	catalog, err := part.LoadCatalog(db)
+	if err != nil {
+		return result, fmt.Errorf("gagal mengambil katalog part: %w", err)
+	}
+	bomItems, err = comparisonLines(db, catalog, bomItems, view)
+	if err != nil {
+		return result, fmt.Errorf("gagal menguraikan sub-assembly BOM: %w", err)
+	}
+
+	aliases, err := loadAliases(db, catalog)
+	if err != nil {
+		return result, fmt.Errorf("gagal mengambil mapping kelas deteksi: %w", err)
+	}
+
+	tolerances, err := tolerance.LoadForBOM(db, bomCode)
+	if err != nil {
+		return result, fmt.Errorf("gagal mengambil aturan toleransi: %w", err)
+	}
+
+	minConfidence, err := threshold.LoadAll(db)
+	if err != nil {
+		return result, fmt.Errorf("gagal mengambil ambang confidence: %w", err)
+	}
+
+	result = compareBOMAndDetections(bomItems, combineDetections(images, strategy), comparisonRules{
+		Aliases:       aliases,
+		Tolerances:    tolerances,
+		MinConfidence: minConfidence,
+	})
+	result.BomRevision = bomRevision
+	result.BomView = view
+	result.CountStrategy = strategy
+
+	if modelID != nil {
+		m, err := getModel(db, *modelID)
+		if err != nil && err != sql.ErrNoRows {
+			return result, fmt.Errorf("gagal mengambil model deteksi: %w", err)
+		}
+		if m != nil {
+			result.UncoveredParts = uncoveredParts(bomItems, aliases, m.Classes)
+		}
+	}
+	return result, nil
+}
+
+// imageResults turns stored images into their API form with signed links.
+func imageResults(blobs *storage.Blobs, images []detectedImage) []models.InspectionImage {
+	results := make([]models.InspectionImage, 0, len(images))
+	for _, img := range images {
+		results = append(results, models.InspectionImage{
+			FileName:       img.FileName,
+			OriginalImage:  blobs.URL(img.OriginalKey),
+			AnnotatedImage: blobs.URL(img.AnnotatedKey),
+			Detections:     img.Detections,
+		})
+	}
+	return results
+}
+
+// saveRunImages stores the images of a run in order.
+func saveRunImages(tx *sql.Tx, runID int, images []detectedImage) error {
+	stmt, err := tx.Prepare(`
+		INSERT INTO detection_run_images (run_id, position, file_name, original_image, annotated_image, detections)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, img := range images {
+		detectionsJSON, err := json.Marshal(img.Detections)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(runID, i+1, img.FileName, img.OriginalKey, img.AnnotatedKey, detectionsJSON); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRunImages returns the images of a run. Runs from before multi-image
+// inspections have none; their single image is on the run itself.
+func loadRunImages(db *sql.DB, blobs *storage.Blobs, runID int) ([]models.InspectionImage, error) {
+	rows, err := db.Query(`
+		SELECT file_name, original_image, annotated_image, detections
+		FROM detection_run_images
+		WHERE run_id = $1
+		ORDER BY position
+	`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows)
+	if err != nil {
+		return nil, err
+	}
+	return imageResults(blobs, images), nil
+}
+
+// scanImages reads rows of file name, original key, annotated key and detections JSON.
+func scanImages(rows *sql.Rows) ([]detectedImage, error) {
+	var images []detectedImage
+	for rows.Next() {
+		var img detectedImage
+		var detectionsJSON []byte
+		if err := rows.Scan(&img.FileName, &img.OriginalKey, &img.AnnotatedKey, &detectionsJSON); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(detectionsJSON, &img.Detections); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
@@ -0,0 +1,313 @@
+package detection
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"yolo-server/detector"
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobPollInterval bounds how long a queued job waits when no wake-up signal arrives,
+// e.g. for jobs left over from before a restart.
+const jobPollInterval = 5 * time.Second
+
+// A running job that started more than jobStaleAfter ago is taken to have been
+// interrupted by a shutdown. It is run again until it has made maxJobAttempts.
+const (
+	jobStaleAfter  = 15 * time.Minute
+	maxJobAttempts = 3
+)
+
+// JobQueue runs detections in the background. Jobs live in detection_jobs, so
+// queued work survives a restart; workers claim them with SKIP LOCKED.
+type JobQueue struct {
+	db       *sql.DB
+	detector detector.Detector
+	blobs    *storage.Blobs
+	workers  int
+	wake     chan struct{}
+}
+
+func NewJobQueue(db *sql.DB, det detector.Detector, blobs *storage.Blobs, workers int) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	return &JobQueue{
+		db:       db,
+		detector: det,
+		blobs:    blobs,
+		workers:  workers,
+		wake:     make(chan struct{}, workers),
+	}
+}
+
+// Start puts jobs interrupted by a previous shutdown back in the queue and starts the
+// workers. Jobs that started recently may still be running on another server and
+// are left alone; those that were already tried maxJobAttempts times fail.
+func (q *JobQueue) Start(ctx context.Context) error {
+	if err := q.failExhausted(ctx); err != nil {
+		return err
+	}
+	requeue := `
+		UPDATE detection_jobs SET status = 'queued', started_at = NULL
+		WHERE status = 'running' AND started_at < NOW() - $1 * INTERVAL '1 second'
+	`
+	if _, err := q.db.Exec(requeue, jobStaleAfter.Seconds()); err != nil {
+		return err
+	}
+	for i := 0; i < q.workers; i++ {
+		go q.work(ctx)
+	}
+	return nil
+}
+
+// failExhausted fails the interrupted jobs that have no attempts left and deletes
+// their uploaded weights.
+func (q *JobQueue) failExhausted(ctx context.Context) error {
+	query := `
+		UPDATE detection_jobs
+		SET status = 'failed', error = $1, finished_at = NOW()
+		WHERE status = 'running' AND started_at < NOW() - $2 * INTERVAL '1 second' AND attempts >= $3
+		RETURNING id, model_key
+	`
+	msg := fmt.Sprintf("job terhenti %d kali sebelum selesai", maxJobAttempts)
+	rows, err := q.db.Query(query, msg, jobStaleAfter.Seconds(), maxJobAttempts)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID, modelKey string
+		if err := rows.Scan(&jobID, &modelKey); err != nil {
+			return err
+		}
+		log.Printf("Job deteksi %s gagal: %s", jobID, msg)
+		if modelKey != "" {
+			if err := q.blobs.Delete(ctx, modelKey); err != nil {
+				log.Printf("Gagal menghapus file model job %s: %v", jobID, err)
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// Enqueue records a job for images, and custom weights if any, that are
+// already in blob storage.
+func (q *JobQueue) Enqueue(in detectionInput) (string, error) {
+	paramsJSON, err := json.Marshal(in.Params)
+	if err != nil {
+		return "", err
+	}
+	imagesJSON, err := json.Marshal(in.Images)
+	if err != nil {
+		return "", err
+	}
+
+	// file_name and image_key name the first image, as for single-image jobs.
+	var jobID string
+	query := `
+		INSERT INTO detection_jobs (bom_code, file_name, image_key, images, count_strategy, bom_view, params, model_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	first := in.Images[0]
+	if err := q.db.QueryRow(query, in.BomCode, first.FileName, first.Key, imagesJSON, in.Strategy, in.View, paramsJSON, in.ModelKey).Scan(&jobID); err != nil {
+		return "", err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return jobID, nil
+}
+
+func (q *JobQueue) work(ctx context.Context) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		claimed, err := q.runNext(ctx)
+		if err != nil {
+			log.Printf("Worker deteksi gagal mengambil job: %v", err)
+		}
+		if claimed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims the oldest queued job and runs it. It reports whether a job was claimed.
+func (q *JobQueue) runNext(ctx context.Context) (bool, error) {
+	var jobID string
+	var in detectionInput
+	var first inputImage
+	var imagesJSON, paramsJSON []byte
+	claim := `
+		UPDATE detection_jobs
+		SET status = 'running', started_at = NOW(), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM detection_jobs
+			WHERE status = 'queued'
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, bom_code, file_name, image_key, images, count_strategy, bom_view, params, model_key
+	`
+	err := q.db.QueryRow(claim).Scan(&jobID, &in.BomCode, &first.FileName, &first.Key, &imagesJSON, &in.Strategy, &in.View, &paramsJSON, &in.ModelKey)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = q.loadInput(ctx, &in, first, imagesJSON, paramsJSON)
+	var result *models.ComparisonResult
+	if err == nil {
+		result, err = runDetection(ctx, q.db, q.detector, q.blobs, in)
+	}
+
+	// The job ends here either way. The run records the model name; the weights
+	// themselves are not kept.
+	if in.ModelKey != "" {
+		if err := q.blobs.Delete(ctx, in.ModelKey); err != nil {
+			log.Printf("Gagal menghapus file model job %s: %v", jobID, err)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Job deteksi %s gagal: %v", jobID, err)
+		_, dbErr := q.db.Exec(
+			"UPDATE detection_jobs SET status = 'failed', error = $1, finished_at = NOW() WHERE id = $2",
+			err.Error(), jobID,
+		)
+		return true, dbErr
+	}
+
+	// Images are served from the run, so the job only keeps the comparison itself.
+	result.OriginalImage, result.AnnotatedImage, result.Images = "", "", nil
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return true, err
+	}
+	_, err = q.db.Exec(`
+		UPDATE detection_jobs
+		SET status = 'succeeded', run_id = $1, comparison_result_json = $2, finished_at = NOW()
+		WHERE id = $3
+	`, result.RunID, resultJSON, jobID)
+	return true, err
+}
+
+// loadInput restores what runNext claimed: the parameters, the images (jobs from
+// before multi-image inspections only have first) and any custom weights.
+func (q *JobQueue) loadInput(ctx context.Context, in *detectionInput, first inputImage, imagesJSON, paramsJSON []byte) error {
+	if err := json.Unmarshal(paramsJSON, &in.Params); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(imagesJSON, &in.Images); err != nil {
+		return err
+	}
+	if len(in.Images) == 0 {
+		in.Images = []inputImage{first}
+	}
+	for i := range in.Images {
+		var err error
+		if in.Images[i].Data, err = q.loadImage(ctx, in.Images[i].Key); err != nil {
+			return err
+		}
+	}
+	if in.ModelKey != "" {
+		var err error
+		in.Model, err = q.loadImage(ctx, in.ModelKey)
+		return err
+	}
+	return nil
+}
+
+func (q *JobQueue) loadImage(ctx context.Context, key string) ([]byte, error) {
+	r, _, err := q.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca file job %s: %w", key, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func GetJob(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	var job models.DetectionJob
+	var errMsg sql.NullString
+	var runID sql.NullInt64
+	var resultJSON []byte
+	var startedAt, finishedAt sql.NullTime
+	var originalKey, annotatedKey sql.NullString
+
+	query := `
+		SELECT j.id, j.bom_code, j.status, j.attempts, j.error, j.run_id, j.comparison_result_json,
+			j.created_at, j.started_at, j.finished_at, r.original_image, r.annotated_image
+		FROM detection_jobs j
+		LEFT JOIN detection_runs r ON r.id = j.run_id
+		WHERE j.id::text = $1
+	`
+	err := db.QueryRow(query, c.Param("id")).Scan(
+		&job.ID, &job.BomCode, &job.Status, &job.Attempts, &errMsg, &runID,
+		&resultJSON, &job.CreatedAt, &startedAt, &finishedAt, &originalKey, &annotatedKey,
+	)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job: " + err.Error()})
+		return
+	}
+
+	job.Error = errMsg.String
+	if runID.Valid {
+		id := int(runID.Int64)
+		job.RunID = &id
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if len(resultJSON) > 0 {
+		var result models.ComparisonResult
+		if err := json.Unmarshal(resultJSON, &result); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse comparison result JSON: " + err.Error()})
+			return
+		}
+		result.OriginalImage = blobs.URL(originalKey.String)
+		result.AnnotatedImage = blobs.URL(annotatedKey.String)
+		if runID.Valid {
+			if result.Images, err = loadRunImages(db, blobs, int(runID.Int64)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection images: " + err.Error()})
+				return
+			}
+		}
+		job.Result = &result
+	}
+
+	c.JSON(http.StatusOK, job)
+}
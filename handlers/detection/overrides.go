@@ -0,0 +1,207 @@
+package detection
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"yolo-server/handlers/bom"
+	"yolo-server/handlers/part"
+	"yolo-server/handlers/threshold"
+	"yolo-server/handlers/tolerance"
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateDetectionCounts lets an inspector override detected quantities of the latest
+// run. The comparison is recomputed from the stored model output, and every change
+// is written to detection_count_overrides for auditing and retraining.
+func UpdateDetectionCounts(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	bomCode := c.Param("bomCode")
+
+	var req models.CountOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	for _, o := range req.Overrides {
+		if o.Quantity < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must not be negative"})
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var resultJSON []byte
+	var runID sql.NullInt64
+	var originalKey, annotatedKey string
+	var finalized bool
+	err = tx.QueryRow(
+		"SELECT comparison_result_json, latest_run_id, original_image, annotated_image, COALESCE(is_finalized, FALSE) FROM detection_results WHERE bom_code = $1 FOR UPDATE",
+		bomCode,
+	).Scan(&resultJSON, &runID, &originalKey, &annotatedKey, &finalized)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No detection result found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection result: " + err.Error()})
+		return
+	}
+
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": errInspectionFinalized.Error()})
+		return
+	}
+
+	var stored models.ComparisonResult
+	if err := json.Unmarshal(resultJSON, &stored); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse comparison result JSON: " + err.Error()})
+		return
+	}
+
+	bomItems, err := bom.LinesForRevision(db, bomCode, stored.BomRevision)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM"})
+		return
+	}
+	catalog, err := part.LoadCatalog(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parts catalog"})
+		return
+	}
+	bomItems, err = comparisonLines(db, catalog, bomItems, stored.BomView)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to explode BOM: " + err.Error()})
+		return
+	}
+	aliases, err := loadAliases(db, catalog)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch class aliases"})
+		return
+	}
+
+	tolerances, err := tolerance.LoadForBOM(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tolerance rules"})
+		return
+	}
+
+	minConfidence, err := threshold.LoadAll(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch confidence thresholds"})
+		return
+	}
+
+	// Earlier overrides stay in place unless the same part is overridden again.
+	rules := comparisonRules{
+		Aliases:       aliases,
+		Overrides:     make(map[string]models.CountOverride),
+		Tolerances:    tolerances,
+		MinConfidence: minConfidence,
+	}
+	for _, o := range stored.CountOverrides {
+		rules.Overrides[o.PartName] = o
+	}
+
+	known := knownPartNames(bomItems, stored)
+	changed := make(map[string]bool)
+	now := time.Now()
+	for _, o := range req.Overrides {
+		if !known[o.PartName] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Part " + o.PartName + " is neither in the BOM nor detected"})
+			return
+		}
+		changed[o.PartName] = true
+		rules.Overrides[o.PartName] = models.CountOverride{
+			OverrideCount: o.Quantity,
+			Reason:        o.Reason,
+			ChangedBy:     req.ChangedBy,
+			ChangedAt:     now,
+		}
+	}
+
+	result := compareBOMAndDetections(bomItems, stored.Detections, rules)
+	result.RunID = stored.RunID
+	result.BomRevision = stored.BomRevision
+	result.BomView = stored.BomView
+	result.DetectionParams = stored.DetectionParams
+	result.CountStrategy = stored.CountStrategy
+
+	audit, err := tx.Prepare(`
+		INSERT INTO detection_count_overrides (bom_code, run_id, part_name, model_count, override_count, reason, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare statement"})
+		return
+	}
+	defer audit.Close()
+	for _, o := range result.CountOverrides {
+		if !changed[o.PartName] {
+			continue
+		}
+		if _, err := audit.Exec(bomCode, runID, o.PartName, o.ModelCount, o.OverrideCount, o.Reason, o.ChangedBy, o.ChangedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record override"})
+			return
+		}
+	}
+
+	updatedJSON, err := json.Marshal(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode comparison result"})
+		return
+	}
+	if _, err := tx.Exec("UPDATE detection_results SET comparison_result_json = $1 WHERE bom_code = $2", updatedJSON, bomCode); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update detection result"})
+		return
+	}
+	if runID.Valid {
+		if _, err := tx.Exec("UPDATE detection_runs SET comparison_result_json = $1 WHERE id = $2", updatedJSON, runID.Int64); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update detection run"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Gagal commit override untuk %s: %v", bomCode, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	result.OriginalImage = blobs.URL(originalKey)
+	result.AnnotatedImage = blobs.URL(annotatedKey)
+	if runID.Valid {
+		if result.Images, err = loadRunImages(db, blobs, int(runID.Int64)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection images: " + err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// knownPartNames lists the names an override may target: BOM parts and the parts
+// that appear in the stored comparison.
+func knownPartNames(bomItems []models.BOMEntry, stored models.ComparisonResult) map[string]bool {
+	known := make(map[string]bool)
+	for _, item := range bomItems {
+		known[item.PartName] = true
+	}
+	for _, item := range stored.SurplusItems {
+		known[item.PartName] = true
+	}
+	for _, d := range stored.Detections {
+		known[d.ClassName] = true
+	}
+	return known
+}
@@ -0,0 +1,343 @@
+package detection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const defaultsColumns = "id, COALESCE(bom_code, ''), COALESCE(category, ''), conf, iou, agnostic_nms, model_id, created_at, updated_at"
+
+func scanDefaults(row interface{ Scan(...any) error }, d *models.DetectionDefaults) error {
+	return row.Scan(&d.ID, &d.BomCode, &d.Category, &d.Conf, &d.IoU, &d.AgnosticNMS, &d.ModelID, &d.CreatedAt, &d.UpdatedAt)
+}
+
+// validateThreshold checks a conf or iou value; the predictor expects (0, 1].
+func validateThreshold(name string, value *float64) error {
+	if value != nil && (*value <= 0 || *value > 1) {
+		return fmt.Errorf("%s harus lebih dari 0 dan paling besar 1", name)
+	}
+	return nil
+}
+
+// formOrQuery returns a form field, or the query parameter of the same name.
+func formOrQuery(c *gin.Context, name string) string {
+	if v := c.PostForm(name); v != "" {
+		return v
+	}
+	return c.Query(name)
+}
+
+// parseDetectionParams reads conf, iou and agnostic_nms from the form, like the
+// predictor does, or from the query string.
+func parseDetectionParams(c *gin.Context) (models.DetectionParams, error) {
+	var params models.DetectionParams
+
+	for _, field := range []struct {
+		name string
+		dst  **float64
+	}{{"conf", &params.Conf}, {"iou", &params.IoU}} {
+		raw := formOrQuery(c, field.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return params, fmt.Errorf("%s tidak valid: %s", field.name, raw)
+		}
+		if err := validateThreshold(field.name, &v); err != nil {
+			return params, err
+		}
+		*field.dst = &v
+	}
+	if raw := formOrQuery(c, "agnostic_nms"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return params, fmt.Errorf("agnostic_nms tidak valid: %s", raw)
+		}
+		params.AgnosticNMS = &v
+	}
+	return params, nil
+}
+
+// isWeightsFile reports whether name is a PyTorch weights file, the only format
+// the predictor loads.
+func isWeightsFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pt")
+}
+
+// readModelFile returns the custom weights uploaded as the "model" field, if any.
+func readModelFile(c *gin.Context) (string, []byte, error) {
+	fileHeader, err := c.FormFile("model")
+	if err != nil {
+		return "", nil, nil
+	}
+	name := filepath.Base(fileHeader.Filename)
+	if !isWeightsFile(name) {
+		return "", nil, fmt.Errorf("file model harus berformat .pt")
+	}
+	data, err := readFileHeader(fileHeader)
+	if err != nil {
+		return "", nil, fmt.Errorf("gagal membaca file model: %w", err)
+	}
+	return name, data, nil
+}
+
+// saveModelFile keeps uploaded weights in blob storage until a queued job has used them.
+func saveModelFile(ctx context.Context, blobs *storage.Blobs, bomCode, name string, data []byte) (string, error) {
+	key := fmt.Sprintf("detection-models/%s/%d-%s", storage.KeySegment(bomCode), time.Now().UnixNano(), storage.KeySegment(name))
+	if err := blobs.Put(ctx, key, data, "application/octet-stream"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// mergeDefaults fills the parameters a request left unset from stored defaults.
+func mergeDefaults(params *models.DetectionParams, d models.DetectionDefaults) {
+	if params.Conf == nil {
+		params.Conf = d.Conf
+	}
+	if params.IoU == nil {
+		params.IoU = d.IoU
+	}
+	if params.AgnosticNMS == nil {
+		params.AgnosticNMS = d.AgnosticNMS
+	}
+}
+
+// bomFamily returns the part family of a BOM: the catalog category that most
+// of its lines belong to, or "" when none of them refers to a categorized part.
+func bomFamily(db *sql.DB, bomCode string) (string, error) {
+	var category string
+	err := db.QueryRow(`
+		SELECT p.category
+		FROM boms b
+		JOIN parts p ON p.part_number = b.part_reference
+		WHERE b.bom_code = $1 AND p.category <> ''
+		GROUP BY p.category
+		ORDER BY COUNT(*) DESC, p.category
+		LIMIT 1
+	`, bomCode).Scan(&category)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return category, err
+}
+
+// defaultModelID returns the registry model named by the detection defaults of
+// the BOM or, failing that, of its part family, or nil.
+func defaultModelID(db *sql.DB, bomCode string) (*int, error) {
+	var modelID int
+	err := db.QueryRow("SELECT model_id FROM detection_defaults WHERE bom_code = $1 AND model_id IS NOT NULL", bomCode).Scan(&modelID)
+	if err == nil {
+		return &modelID, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	family, err := bomFamily(db, bomCode)
+	if err != nil || family == "" {
+		return nil, err
+	}
+	err = db.QueryRow("SELECT model_id FROM detection_defaults WHERE category = $1 AND model_id IS NOT NULL", family).Scan(&modelID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &modelID, nil
+}
+
+// resolveParams completes the requested parameters with the defaults of the
+// BOM and then with those of its part family. Without uploaded weights the
+// registry model of the BOM is used, see bomModel.
+func resolveParams(db *sql.DB, bomCode string, params models.DetectionParams) (models.DetectionParams, error) {
+	if params.Model == "" {
+		m, _, err := bomModel(db, bomCode)
+		if err != nil {
+			return params, err
+		}
+		if m != nil {
+			params.Model = modelLabel(*m)
+			params.ModelID = &m.ID
+		}
+	}
+
+	var d models.DetectionDefaults
+	err := scanDefaults(db.QueryRow("SELECT "+defaultsColumns+" FROM detection_defaults WHERE bom_code = $1", bomCode), &d)
+	if err != nil && err != sql.ErrNoRows {
+		return params, err
+	}
+	if err == nil {
+		mergeDefaults(&params, d)
+	}
+
+	family, err := bomFamily(db, bomCode)
+	if err != nil || family == "" {
+		return params, err
+	}
+	d = models.DetectionDefaults{}
+	err = scanDefaults(db.QueryRow("SELECT "+defaultsColumns+" FROM detection_defaults WHERE category = $1", family), &d)
+	if err == sql.ErrNoRows {
+		return params, nil
+	}
+	if err != nil {
+		return params, err
+	}
+	mergeDefaults(&params, d)
+	return params, nil
+}
+
+func validateDefaults(d models.DetectionDefaults) error {
+	if (d.BomCode == "") == (d.Category == "") {
+		return errors.New("exactly one of bomCode and category is required")
+	}
+	if err := validateThreshold("conf", d.Conf); err != nil {
+		return err
+	}
+	return validateThreshold("iou", d.IoU)
+}
+
+// GetDetectionDefaults lists stored detection parameters; ?bomCode= and
+// ?category= filter on exact values.
+func GetDetectionDefaults(c *gin.Context, db *sql.DB) {
+	query := "SELECT " + defaultsColumns + ` FROM detection_defaults
+		WHERE ($1 = '' OR bom_code = $1) AND ($2 = '' OR category = $2)
+		ORDER BY bom_code NULLS LAST, category`
+	rows, err := db.Query(query, c.Query("bomCode"), c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection defaults"})
+		return
+	}
+	defer rows.Close()
+
+	defaults := []models.DetectionDefaults{}
+	for rows.Next() {
+		var d models.DetectionDefaults
+		if err := scanDefaults(rows, &d); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan detection defaults"})
+			return
+		}
+		defaults = append(defaults, d)
+	}
+	c.JSON(http.StatusOK, defaults)
+}
+
+// defaultsWriteError maps constraint violations of detection_defaults to responses.
+func defaultsWriteError(c *gin.Context, d models.DetectionDefaults, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			c.JSON(http.StatusConflict, gin.H{"error": "Detection defaults for " + d.BomCode + d.Category + " already exist"})
+			return
+		case "23503":
+			if pqErr.Constraint == "detection_defaults_model_id_fkey" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Detection model not found"})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "BOM " + d.BomCode + " not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save detection defaults: " + err.Error()})
+}
+
+func CreateDetectionDefaults(c *gin.Context, db *sql.DB) {
+	var d models.DetectionDefaults
+	if err := c.ShouldBindJSON(&d); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	d.BomCode = strings.TrimSpace(d.BomCode)
+	d.Category = strings.TrimSpace(d.Category)
+	if err := validateDefaults(d); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `
+		INSERT INTO detection_defaults (bom_code, category, conf, iou, agnostic_nms, model_id)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), $3, $4, $5, $6)
+		RETURNING ` + defaultsColumns
+	if err := scanDefaults(db.QueryRow(query, d.BomCode, d.Category, d.Conf, d.IoU, d.AgnosticNMS, d.ModelID), &d); err != nil {
+		defaultsWriteError(c, d, err)
+		return
+	}
+	c.JSON(http.StatusCreated, d)
+}
+
+// UpdateDetectionDefaults replaces the parameters of a defaults entry; a
+// parameter left out is cleared. The BOM code or category stays the same.
+func UpdateDetectionDefaults(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid detection defaults ID"})
+		return
+	}
+
+	var params models.DetectionParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := validateThreshold("conf", params.Conf); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateThreshold("iou", params.IoU); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var d models.DetectionDefaults
+	query := `
+		UPDATE detection_defaults
+		SET conf = $1, iou = $2, agnostic_nms = $3, model_id = $4
+		WHERE id = $5
+		RETURNING ` + defaultsColumns
+	err = scanDefaults(db.QueryRow(query, params.Conf, params.IoU, params.AgnosticNMS, params.ModelID, id), &d)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detection defaults not found"})
+		return
+	}
+	if err != nil {
+		defaultsWriteError(c, d, err)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func DeleteDetectionDefaults(c *gin.Context, db *sql.DB) {
+	result, err := db.Exec("DELETE FROM detection_defaults WHERE id = $1", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete detection defaults"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check affected rows"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detection defaults not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Detection defaults deleted successfully"})
+}
@@ -0,0 +1,580 @@
+package detection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"yolo-server/handlers/bom"
+	"yolo-server/handlers/part"
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const modelColumns = "id, name, version, classes, notes, file_name, weights_key, is_default, created_at, updated_at"
+
+func scanModel(row interface{ Scan(...any) error }, m *models.DetectionModel) error {
+	return row.Scan(&m.ID, &m.Name, &m.Version, pq.Array(&m.Classes), &m.Notes, &m.FileName, &m.WeightsKey, &m.IsDefault, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// modelLabel names a registry model on detection runs.
+func modelLabel(m models.DetectionModel) string {
+	return m.Name + ":" + m.Version
+}
+
+// parseClasses accepts class names as repeated values, comma-separated, or both.
+func parseClasses(values []string) []string {
+	classes := []string{}
+	for _, value := range values {
+		for _, class := range strings.Split(value, ",") {
+			class = strings.TrimSpace(class)
+			if class != "" && !slices.Contains(classes, class) {
+				classes = append(classes, class)
+			}
+		}
+	}
+	return classes
+}
+
+func getModel(db *sql.DB, id int) (*models.DetectionModel, error) {
+	var m models.DetectionModel
+	if err := scanModel(db.QueryRow("SELECT "+modelColumns+" FROM detection_models WHERE id = $1", id), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// bomModel returns the model assigned to a BOM with the time of assignment.
+// Otherwise it returns, with a nil time, the model of the detection defaults of
+// the BOM or of its part family, or else the registry default. The model is nil
+// when there is none of these.
+func bomModel(db *sql.DB, bomCode string) (*models.DetectionModel, *time.Time, error) {
+	var modelID int
+	var assignedAt time.Time
+	err := db.QueryRow("SELECT model_id, assigned_at FROM bom_model_assignments WHERE bom_code = $1", bomCode).Scan(&modelID, &assignedAt)
+	if err == nil {
+		m, err := getModel(db, modelID)
+		return m, &assignedAt, err
+	}
+	if err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+
+	defaultID, err := defaultModelID(db, bomCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if defaultID != nil {
+		m, err := getModel(db, *defaultID)
+		return m, nil, err
+	}
+
+	var m models.DetectionModel
+	err = scanModel(db.QueryRow("SELECT "+modelColumns+" FROM detection_models WHERE is_default"), &m)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &m, nil, nil
+}
+
+// loadModelWeights reads the weights of a registry model from blob storage and
+// returns them with the name of the uploaded .pt file.
+func loadModelWeights(ctx context.Context, db *sql.DB, blobs *storage.Blobs, id int) ([]byte, string, error) {
+	m, err := getModel(db, id)
+	if err != nil {
+		return nil, "", err
+	}
+	r, _, err := blobs.Get(ctx, m.WeightsKey)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+	weights, err := io.ReadAll(r)
+	return weights, m.FileName, err
+}
+
+// uncoveredParts lists the BOM parts that no class of the model would be
+// counted toward, neither as the part itself nor as one of its alternates.
+// Classes are the ones the comparison would look for.
+func uncoveredParts(lines []models.BOMEntry, aliases []models.PartClassAlias, classes []string) []models.UncoveredPart {
+	uncovered := []models.UncoveredPart{}
+	for _, req := range groupRequirements(lines) {
+		candidates := []*bomRequirement{req}
+		for _, alt := range req.Alternates {
+			candidates = append(candidates, alternateRequirement(alt))
+		}
+
+		covered := false
+		var expected []string
+		for _, candidate := range candidates {
+			partAliases, _ := classAliases(candidate, aliases)
+			for _, a := range partAliases {
+				if !slices.Contains(expected, a.ClassName) {
+					expected = append(expected, a.ClassName)
+				}
+			}
+			if slices.ContainsFunc(classes, func(className string) bool { return matchesClass(partAliases, className) }) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			uncovered = append(uncovered, models.UncoveredPart{PartName: req.PartName, Classes: expected})
+		}
+	}
+	return uncovered
+}
+
+// modelCoverage checks a model against the lines a detection of the BOM would
+// currently be compared with.
+func modelCoverage(db *sql.DB, bomCode, view string, m *models.DetectionModel) (*models.ModelCoverage, error) {
+	lines, _, err := bom.EffectiveLines(db, bomCode, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := part.LoadCatalog(db)
+	if err != nil {
+		return nil, err
+	}
+	lines, err = comparisonLines(db, catalog, lines, view)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := loadAliases(db, catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	uncovered := uncoveredParts(lines, aliases, m.Classes)
+	return &models.ModelCoverage{
+		BomCode:        bomCode,
+		ModelID:        m.ID,
+		View:           view,
+		Covered:        len(uncovered) == 0,
+		UncoveredParts: uncovered,
+	}, nil
+}
+
+// clearDefault unmarks the current default model, except keep.
+func clearDefault(tx *sql.Tx, keep int) error {
+	_, err := tx.Exec("UPDATE detection_models SET is_default = FALSE WHERE is_default AND id <> $1", keep)
+	return err
+}
+
+func GetDetectionModels(c *gin.Context, db *sql.DB) {
+	rows, err := db.Query("SELECT " + modelColumns + " FROM detection_models ORDER BY name, created_at DESC")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection models"})
+		return
+	}
+	defer rows.Close()
+
+	registry := []models.DetectionModel{}
+	for rows.Next() {
+		var m models.DetectionModel
+		if err := scanModel(rows, &m); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan detection model"})
+			return
+		}
+		registry = append(registry, m)
+	}
+	c.JSON(http.StatusOK, registry)
+}
+
+// modelParam looks up the model of the :id route parameter, responding with an
+// error and returning nil when there is none.
+func modelParam(c *gin.Context, db *sql.DB) *models.DetectionModel {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid detection model ID"})
+		return nil
+	}
+	m, err := getModel(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detection model not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection model"})
+		return nil
+	}
+	return m
+}
+
+func GetDetectionModel(c *gin.Context, db *sql.DB) {
+	if m := modelParam(c, db); m != nil {
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// CreateDetectionModel uploads weights to the registry as multipart form data:
+// the .pt file in "file", plus "name", "version", "classes", "notes" and "isDefault".
+func CreateDetectionModel(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weights file is required"})
+		return
+	}
+	m := models.DetectionModel{
+		Name:     strings.TrimSpace(c.PostForm("name")),
+		Version:  strings.TrimSpace(c.PostForm("version")),
+		Classes:  parseClasses(c.PostFormArray("classes")),
+		Notes:    c.PostForm("notes"),
+		FileName: filepath.Base(fileHeader.Filename),
+	}
+	if m.Name == "" || m.Version == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and version are required"})
+		return
+	}
+	if len(m.Classes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "classes must list the classes the model detects"})
+		return
+	}
+	if !isWeightsFile(m.FileName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weights file must be a .pt file"})
+		return
+	}
+	if raw := c.PostForm("isDefault"); raw != "" {
+		if m.IsDefault, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid isDefault: " + raw})
+			return
+		}
+	}
+
+	data, err := readFileHeader(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read weights file"})
+		return
+	}
+	ctx := c.Request.Context()
+	m.WeightsKey = fmt.Sprintf("model-registry/%s/%s/%d-%s", storage.KeySegment(m.Name), storage.KeySegment(m.Version), time.Now().UnixNano(), storage.KeySegment(m.FileName))
+	if err := blobs.Put(ctx, m.WeightsKey, data, "application/octet-stream"); err != nil {
+		log.Printf("Gagal menyimpan bobot model %s: %v", modelLabel(m), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store weights file"})
+		return
+	}
+
+	err = insertModel(db, &m)
+	if err != nil {
+		if delErr := blobs.Delete(ctx, m.WeightsKey); delErr != nil {
+			log.Printf("Gagal menghapus bobot model %s: %v", m.WeightsKey, delErr)
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Model " + modelLabel(m) + " already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save detection model: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, m)
+}
+
+func insertModel(db *sql.DB, m *models.DetectionModel) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.IsDefault {
+		if err := clearDefault(tx, 0); err != nil {
+			return err
+		}
+	}
+	query := `
+		INSERT INTO detection_models (name, version, classes, notes, file_name, weights_key, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + modelColumns
+	row := tx.QueryRow(query, m.Name, m.Version, pq.Array(m.Classes), m.Notes, m.FileName, m.WeightsKey, m.IsDefault)
+	if err := scanModel(row, m); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// assignedBOMs returns the codes of the BOMs a model is assigned to.
+func assignedBOMs(db *sql.DB, modelID int) ([]string, error) {
+	rows, err := db.Query("SELECT bom_code FROM bom_model_assignments WHERE model_id = $1 ORDER BY bom_code", modelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bomCodes []string
+	for rows.Next() {
+		var bomCode string
+		if err := rows.Scan(&bomCode); err != nil {
+			return nil, err
+		}
+		bomCodes = append(bomCodes, bomCode)
+	}
+	return bomCodes, rows.Err()
+}
+
+// assignedCoverage checks a model against every BOM it is assigned to and
+// returns the coverage of those it does not cover.
+func assignedCoverage(db *sql.DB, view string, m *models.DetectionModel) ([]models.ModelCoverage, error) {
+	bomCodes, err := assignedBOMs(db, m.ID)
+	if err != nil {
+		return nil, err
+	}
+	uncovered := []models.ModelCoverage{}
+	for _, bomCode := range bomCodes {
+		coverage, err := modelCoverage(db, bomCode, view, m)
+		if err != nil {
+			return nil, err
+		}
+		if !coverage.Covered {
+			uncovered = append(uncovered, *coverage)
+		}
+	}
+	return uncovered, nil
+}
+
+// UpdateDetectionModel changes the class list, notes or default flag of a
+// model. Name, version and weights stay fixed; upload a new version instead.
+// New classes must still cover every BOM the model is assigned to, checked in
+// the ?view= of the BOMs; otherwise their coverage is returned with 422.
+func UpdateDetectionModel(c *gin.Context, db *sql.DB) {
+	m := modelParam(c, db)
+	if m == nil {
+		return
+	}
+
+	var patch struct {
+		Classes   *[]string `json:"classes"`
+		Notes     *string   `json:"notes"`
+		IsDefault *bool     `json:"isDefault"`
+	}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if patch.Classes != nil {
+		m.Classes = parseClasses(*patch.Classes)
+		if len(m.Classes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "classes must list the classes the model detects"})
+			return
+		}
+		view, err := parseBOMView(c.Query("view"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uncovered, err := assignedCoverage(db, view, m)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check model coverage: " + err.Error()})
+			return
+		}
+		if len(uncovered) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":    "Model " + modelLabel(*m) + " would no longer detect every part of its assigned BOMs",
+				"coverage": uncovered,
+			})
+			return
+		}
+	}
+	if patch.Notes != nil {
+		m.Notes = *patch.Notes
+	}
+	if patch.IsDefault != nil {
+		m.IsDefault = *patch.IsDefault
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if m.IsDefault {
+		if err := clearDefault(tx, m.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update default model"})
+			return
+		}
+	}
+	query := `
+		UPDATE detection_models
+		SET classes = $1, notes = $2, is_default = $3
+		WHERE id = $4
+		RETURNING ` + modelColumns
+	if err := scanModel(tx.QueryRow(query, pq.Array(m.Classes), m.Notes, m.IsDefault, m.ID), m); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update detection model: " + err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// DeleteDetectionModel removes a model and its weights. BOMs assigned to it
+// fall back to the default model; past runs keep the model label.
+func DeleteDetectionModel(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	m := modelParam(c, db)
+	if m == nil {
+		return
+	}
+	if _, err := db.Exec("DELETE FROM detection_models WHERE id = $1", m.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete detection model"})
+		return
+	}
+	if err := blobs.Delete(c.Request.Context(), m.WeightsKey); err != nil {
+		log.Printf("Gagal menghapus bobot model %s: %v", m.WeightsKey, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Detection model deleted successfully"})
+}
+
+// GetModelCoverage checks whether a model can detect every part of ?bomCode=,
+// before assigning it.
+func GetModelCoverage(c *gin.Context, db *sql.DB) {
+	m := modelParam(c, db)
+	if m == nil {
+		return
+	}
+	bomCode := c.Query("bomCode")
+	if bomCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bomCode is required"})
+		return
+	}
+	view, err := parseBOMView(c.Query("view"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
+
+	coverage, err := modelCoverage(db, bomCode, view, m)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check model coverage: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, coverage)
+}
+
+// GetBOMModel returns the model detections of the BOM use, with its coverage
+// of the current BOM lines.
+func GetBOMModel(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+	view, err := parseBOMView(c.Query("view"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
+
+	m, assignedAt, err := bomModel(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM model"})
+		return
+	}
+	result := models.BOMModel{BomCode: bomCode, Assigned: assignedAt != nil, AssignedAt: assignedAt, Model: m}
+	if m != nil {
+		if result.Coverage, err = modelCoverage(db, bomCode, view, m); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check model coverage: " + err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// AssignBOMModel assigns a registry model to a BOM. The model must be able to
+// detect every part of the BOM; otherwise the coverage is returned with 422.
+func AssignBOMModel(c *gin.Context, db *sql.DB) {
+	bomCode := c.Param("id")
+	var req struct {
+		ModelID int    `json:"modelId" binding:"required"`
+		View    string `json:"view"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	view, err := parseBOMView(req.View)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
+
+	m, err := getModel(db, req.ModelID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Detection model not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch detection model"})
+		return
+	}
+
+	coverage, err := modelCoverage(db, bomCode, view, m)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check model coverage: " + err.Error()})
+		return
+	}
+	if !coverage.Covered {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":    "Model " + modelLabel(*m) + " cannot detect every part of BOM " + bomCode,
+			"coverage": coverage,
+		})
+		return
+	}
+
+	var assignedAt time.Time
+	err = db.QueryRow(`
+		INSERT INTO bom_model_assignments (bom_code, model_id)
+		VALUES ($1, $2)
+		ON CONFLICT (bom_code) DO UPDATE SET model_id = EXCLUDED.model_id, assigned_at = NOW()
+		RETURNING assigned_at
+	`, bomCode, m.ID).Scan(&assignedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign detection model: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.BOMModel{BomCode: bomCode, Assigned: true, AssignedAt: &assignedAt, Model: m, Coverage: coverage})
+}
+
+// UnassignBOMModel removes the model assignment of a BOM, so it uses the default model again.
+func UnassignBOMModel(c *gin.Context, db *sql.DB) {
+	result, err := db.Exec("DELETE FROM bom_model_assignments WHERE bom_code = $1", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove model assignment"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check affected rows"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "BOM has no model assignment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Model assignment removed successfully"})
+}
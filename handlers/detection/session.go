@@ -0,0 +1,325 @@
+package detection
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"yolo-server/detector"
+	"yolo-server/handlers/bom"
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadSession returns a session of the BOM with its images in the order they were added.
+func loadSession(db *sql.DB, bomCode, sessionID string) (*models.InspectionSession, []detectedImage, error) {
+	var s models.InspectionSession
+	var paramsJSON []byte
+	var runID sql.NullInt64
+	var closedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, bom_code, status, count_strategy, bom_view, params, run_id, created_at, closed_at
+		FROM inspection_sessions
+		WHERE id::text = $1 AND bom_code = $2
+	`, sessionID, bomCode).Scan(&s.ID, &s.BomCode, &s.Status, &s.CountStrategy, &s.BomView, &paramsJSON, &runID, &s.CreatedAt, &closedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(paramsJSON, &s.Params); err != nil {
+		return nil, nil, err
+	}
+	if runID.Valid {
+		id := int(runID.Int64)
+		s.RunID = &id
+	}
+	if closedAt.Valid {
+		s.ClosedAt = &closedAt.Time
+	}
+
+	rows, err := db.Query(`
+		SELECT file_name, original_image, annotated_image, detections
+		FROM inspection_session_images
+		WHERE session_id = $1
+		ORDER BY id
+	`, s.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	images, err := scanImages(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &s, images, nil
+}
+
+// sessionParam loads the session of the route, responding with an error and
+// returning nil when there is none.
+func sessionParam(c *gin.Context, db *sql.DB) (*models.InspectionSession, []detectedImage) {
+	s, images, err := loadSession(db, c.Param("bomCode"), c.Param("sessionId"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection session not found"})
+		return nil, nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection session: " + err.Error()})
+		return nil, nil
+	}
+	return s, images
+}
+
+// respondSession sends a session with its images and, while it is open, a
+// preview of the comparison of the images so far.
+func respondSession(c *gin.Context, db *sql.DB, blobs *storage.Blobs, status int, s *models.InspectionSession, images []detectedImage) {
+	s.Images = imageResults(blobs, images)
+	if s.Status == models.SessionOpen && len(images) > 0 {
+		preview, err := compareImages(db, s.BomCode, s.BomView, s.CountStrategy, s.Params.ModelID, images)
+		if err != nil {
+			log.Printf("Pratinjau sesi inspeksi %s gagal: %v", s.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses hasil deteksi"})
+			return
+		}
+		preview.DetectionParams = &s.Params
+		s.Comparison = &preview
+	}
+	c.JSON(status, s)
+}
+
+// CreateInspectionSession opens a session for photographing a kit that does
+// not fit in one frame. strategy, view and the detection parameters are taken
+// from the form or query string as for a single detection and hold for every
+// image of the session.
+func CreateInspectionSession(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	bomCode := c.Param("bomCode")
+	if !requireBOM(c, db, bomCode) {
+		return
+	}
+	view, err := parseBOMView(c.Query("view"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	strategy, err := parseCountStrategy(formOrQuery(c, "strategy"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params, err := parseDetectionParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	finalized, err := bom.IsFinalized(db, bomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa status finalisasi"})
+		return
+	}
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": errInspectionFinalized.Error()})
+		return
+	}
+
+	params, err = resolveParams(db, bomCode, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menentukan parameter dan model deteksi"})
+		return
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode detection parameters"})
+		return
+	}
+
+	s := models.InspectionSession{BomCode: bomCode, Status: models.SessionOpen, CountStrategy: strategy, BomView: view, Params: params}
+	err = db.QueryRow(`
+		INSERT INTO inspection_sessions (bom_code, status, count_strategy, bom_view, params)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, bomCode, s.Status, strategy, view, paramsJSON).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inspection session: " + err.Error()})
+		return
+	}
+	respondSession(c, db, blobs, http.StatusCreated, &s, nil)
+}
+
+func GetInspectionSession(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	s, images := sessionParam(c, db)
+	if s == nil {
+		return
+	}
+	respondSession(c, db, blobs, http.StatusOK, s, images)
+}
+
+// AddSessionImages runs the predictor on one or more photos ("file" fields) and
+// adds them to an open session.
+func AddSessionImages(c *gin.Context, db *sql.DB, det detector.Detector, blobs *storage.Blobs) {
+	s, images := sessionParam(c, db)
+	if s == nil {
+		return
+	}
+	if s.Status != models.SessionOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Inspection session is closed"})
+		return
+	}
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File gambar tidak ditemukan"})
+		return
+	}
+
+	finalized, err := bom.IsFinalized(db, s.BomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa status finalisasi"})
+		return
+	}
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": errInspectionFinalized.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	uploaded, err := storeImageFiles(ctx, blobs, s.BomCode, form.File["file"])
+	if err != nil {
+		log.Printf("Gagal menyimpan gambar asli: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan gambar asli"})
+		return
+	}
+	added, err := detectImages(ctx, db, det, blobs, s.BomCode, uploaded, s.Params, nil)
+	if err != nil {
+		respondDetectionError(c, s.BomCode, err)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// The session may have been closed while the predictor was running.
+	var status string
+	if err := tx.QueryRow("SELECT status FROM inspection_sessions WHERE id = $1 FOR UPDATE", s.ID).Scan(&status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection session"})
+		return
+	}
+	if status != models.SessionOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Inspection session is closed"})
+		return
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO inspection_session_images (session_id, file_name, original_image, annotated_image, detections)
+		VALUES ($1, $2, $3, $4, $5)
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare statement"})
+		return
+	}
+	defer stmt.Close()
+	for _, img := range added {
+		detectionsJSON, err := json.Marshal(img.Detections)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode detections"})
+			return
+		}
+		if _, err := stmt.Exec(s.ID, img.FileName, img.OriginalKey, img.AnnotatedKey, detectionsJSON); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session image: " + err.Error()})
+			return
+		}
+	}
+	if _, err := tx.Exec("UPDATE inspection_sessions SET updated_at = NOW() WHERE id = $1", s.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inspection session"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	respondSession(c, db, blobs, http.StatusOK, s, append(images, added...))
+}
+
+// CloseInspectionSession compares the combined counts of all images of the
+// session with the BOM and stores them as a detection run.
+func CloseInspectionSession(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	s, _ := sessionParam(c, db)
+	if s == nil {
+		return
+	}
+
+	finalized, err := bom.IsFinalized(db, s.BomCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa status finalisasi"})
+		return
+	}
+	if finalized {
+		c.JSON(http.StatusConflict, gin.H{"error": errInspectionFinalized.Error()})
+		return
+	}
+
+	// Closing first stops further images and a second close from storing another run.
+	closed, err := db.Exec("UPDATE inspection_sessions SET status = $1, closed_at = NOW() WHERE id = $2 AND status = $3", models.SessionClosed, s.ID, models.SessionOpen)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close inspection session"})
+		return
+	}
+	if n, err := closed.RowsAffected(); err != nil || n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Inspection session is closed"})
+		return
+	}
+	reopen := func() {
+		if _, err := db.Exec("UPDATE inspection_sessions SET status = $1, closed_at = NULL WHERE id = $2", models.SessionOpen, s.ID); err != nil {
+			log.Printf("Gagal membuka kembali sesi inspeksi %s: %v", s.ID, err)
+		}
+	}
+
+	_, images, err := loadSession(db, s.BomCode, s.ID)
+	if err != nil {
+		reopen()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection session: " + err.Error()})
+		return
+	}
+	if len(images) == 0 {
+		reopen()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inspection session has no images"})
+		return
+	}
+
+	result, err := compareImages(db, s.BomCode, s.BomView, s.CountStrategy, s.Params.ModelID, images)
+	if err != nil {
+		reopen()
+		respondDetectionError(c, s.BomCode, err)
+		return
+	}
+	result.DetectionParams = &s.Params
+	modelUsed := defaultModelName
+	if s.Params.Model != "" {
+		modelUsed = s.Params.Model
+	}
+	runID, err := saveDetectionRun(db, s.BomCode, modelUsed, images, &result)
+	if errors.Is(err, errInspectionFinalized) {
+		reopen()
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		reopen()
+		log.Printf("Gagal menyimpan hasil sesi inspeksi %s: %v", s.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan hasil deteksi"})
+		return
+	}
+	if _, err := db.Exec("UPDATE inspection_sessions SET run_id = $1 WHERE id = $2", runID, s.ID); err != nil {
+		log.Printf("Gagal mencatat run sesi inspeksi %s: %v", s.ID, err)
+	}
+
+	result.OriginalImage = blobs.URL(images[0].OriginalKey)
+	result.AnnotatedImage = blobs.URL(images[0].AnnotatedKey)
+	result.Images = imageResults(blobs, images)
+	c.JSON(http.StatusOK, result)
+}
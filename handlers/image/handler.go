@@ -0,0 +1,39 @@
+package image
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeImage streams a stored image to holders of a valid signed URL.
+func ServeImage(c *gin.Context, blobs *storage.Blobs) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !blobs.Signer.Verify(key, c.Query("expires"), c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired image link"})
+		return
+	}
+
+	r, contentType, err := blobs.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Gagal membaca gambar %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+		return
+	}
+	defer r.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, r)
+}
@@ -0,0 +1,185 @@
+package part
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"yolo-server/models"
+
+	"github.com/lib/pq"
+)
+
+// suggestThreshold is the lowest name similarity reported as a suggestion.
+const suggestThreshold = 0.75
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Catalog is an in-memory copy of the parts table for resolving BOM lines.
+// A nil *Catalog behaves as an empty one.
+type Catalog struct {
+	parts    []models.Part
+	byNumber map[string]models.Part
+	keys     []string // nameKey of each part, for fuzzy matching
+}
+
+// LoadCatalog reads the whole parts catalog.
+func LoadCatalog(db *sql.DB) (*Catalog, error) {
+	rows, err := db.Query("SELECT " + partColumns + " FROM parts ORDER BY part_number")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cat := &Catalog{byNumber: make(map[string]models.Part)}
+	for rows.Next() {
+		var p models.Part
+		if err := scanPart(rows, &p); err != nil {
+			return nil, err
+		}
+		cat.parts = append(cat.parts, p)
+		cat.byNumber[p.PartNumber] = p
+		cat.keys = append(cat.keys, nameKey(p.Name))
+	}
+	return cat, rows.Err()
+}
+
+// Lookup returns the catalog part with the given number.
+func (cat *Catalog) Lookup(partNumber string) (models.Part, bool) {
+	if cat == nil || partNumber == "" {
+		return models.Part{}, false
+	}
+	p, ok := cat.byNumber[partNumber]
+	return p, ok
+}
+
+// Fill completes a line, and its alternates, that refer to a catalog part but
+// leave the name or description empty.
+func (cat *Catalog) Fill(entry *models.BOMEntry) {
+	if p, ok := cat.Lookup(entry.PartReference); ok {
+		if entry.PartName == "" {
+			entry.PartName = p.Name
+		}
+		if entry.PartDescription == "" {
+			entry.PartDescription = p.Description
+		}
+	}
+	for i := range entry.Alternates {
+		if alt, ok := cat.Lookup(entry.Alternates[i].PartReference); ok && entry.Alternates[i].PartName == "" {
+			entry.Alternates[i].PartName = alt.Name
+		}
+	}
+}
+
+// Canonicalize replaces the names of lines that refer to a catalog part with
+// the catalog name, so the same part is one requirement in every BOM.
+func (cat *Catalog) Canonicalize(lines []models.BOMEntry) []models.BOMEntry {
+	out := make([]models.BOMEntry, len(lines))
+	for i, line := range lines {
+		if p, ok := cat.Lookup(line.PartReference); ok {
+			line.PartName = p.Name
+		}
+		if len(line.Alternates) > 0 {
+			alternates := make([]models.BOMAlternate, len(line.Alternates))
+			for j, alt := range line.Alternates {
+				if p, ok := cat.Lookup(alt.PartReference); ok {
+					alt.PartName = p.Name
+				}
+				alternates[j] = alt
+			}
+			line.Alternates = alternates
+		}
+		out[i] = line
+	}
+	return out
+}
+
+// Aliases turns the detector classes of catalog parts into class aliases.
+func (cat *Catalog) Aliases() []models.PartClassAlias {
+	if cat == nil {
+		return nil
+	}
+	var aliases []models.PartClassAlias
+	for _, p := range cat.parts {
+		if p.DetectorClass != "" {
+			aliases = append(aliases, models.PartClassAlias{PartReference: p.PartNumber, ClassName: p.DetectorClass})
+		}
+	}
+	return aliases
+}
+
+// Match finds the catalog part for a free-text BOM row. A known part number,
+// or a name with the same words as a catalog name when the row has no
+// reference, resolves the row; other close names are returned as suggestions.
+func (cat *Catalog) Match(reference, name string) *models.CatalogMatch {
+	if p, ok := cat.Lookup(reference); ok {
+		return &models.CatalogMatch{PartNumber: p.PartNumber, Name: p.Name, Score: 1, Resolved: true}
+	}
+	if cat == nil || name == "" {
+		return nil
+	}
+
+	key := nameKey(name)
+	best, bestScore := -1, 0.0
+	for i := range cat.parts {
+		score := similarity(key, cat.keys[i])
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore < suggestThreshold {
+		return nil
+	}
+	p := cat.parts[best]
+	return &models.CatalogMatch{
+		PartNumber: p.PartNumber,
+		Name:       p.Name,
+		Score:      float64(int(bestScore*100)) / 100,
+		Resolved:   bestScore == 1 && reference == "",
+	}
+}
+
+// nameKey lowercases a name and sorts its words, so "Handle Drawer" and
+// "drawer  handle" have the same key.
+func nameKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// similarity is 1 minus the edit distance relative to the longer string.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
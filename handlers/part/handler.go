@@ -0,0 +1,270 @@
+package part
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"yolo-server/models"
+	"yolo-server/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const partColumns = "part_number, name, description, unit, category, detector_class, image_key, created_at, updated_at"
+
+const maxPartImageSize = 10 << 20
+
+func scanPart(row interface{ Scan(...any) error }, p *models.Part) error {
+	return row.Scan(&p.PartNumber, &p.Name, &p.Description, &p.Unit, &p.Category, &p.DetectorClass, &p.ImageKey, &p.CreatedAt, &p.UpdatedAt)
+}
+
+func validatePart(p models.Part) string {
+	if strings.TrimSpace(p.PartNumber) == "" {
+		return "partNumber is required"
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		return "name is required"
+	}
+	return ""
+}
+
+func getPart(db *sql.DB, partNumber string) (models.Part, error) {
+	var p models.Part
+	err := scanPart(db.QueryRow("SELECT "+partColumns+" FROM parts WHERE part_number = $1", partNumber), &p)
+	return p, err
+}
+
+// likeEscaper escapes the characters LIKE treats specially; backslash is the
+// default escape character in Postgres.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ContainsPattern returns an ILIKE pattern that matches values containing q
+// literally.
+func ContainsPattern(q string) string {
+	return "%" + likeEscaper.Replace(q) + "%"
+}
+
+// GetParts lists the catalog. ?q= searches part number, name and description;
+// ?category= and ?detectorClass= filter on exact values.
+func GetParts(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+
+	query := "SELECT " + partColumns + ` FROM parts
+		WHERE ($1 = '' OR part_number ILIKE $5 OR name ILIKE $5 OR description ILIKE $5)
+		  AND ($2 = '' OR category = $2)
+		  AND ($3 = '' OR detector_class = $3)
+		ORDER BY part_number
+		LIMIT $4`
+	q := strings.TrimSpace(c.Query("q"))
+	rows, err := db.Query(query, q, c.Query("category"), c.Query("detectorClass"), limit, ContainsPattern(q))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parts"})
+		return
+	}
+	defer rows.Close()
+
+	parts := []models.Part{}
+	for rows.Next() {
+		var p models.Part
+		if err := scanPart(rows, &p); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan part"})
+			return
+		}
+		p.ImageURL = blobs.URL(p.ImageKey)
+		parts = append(parts, p)
+	}
+	c.JSON(http.StatusOK, parts)
+}
+
+func GetPart(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	p, err := getPart(db, c.Param("partNumber"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Part not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part"})
+		return
+	}
+	p.ImageURL = blobs.URL(p.ImageKey)
+	c.JSON(http.StatusOK, p)
+}
+
+func CreatePart(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	var p models.Part
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	p.PartNumber = strings.TrimSpace(p.PartNumber)
+	if msg := validatePart(p); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if p.Unit == "" {
+		p.Unit = "pcs"
+	}
+
+	query := `
+		INSERT INTO parts (part_number, name, description, unit, category, detector_class)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + partColumns
+	row := db.QueryRow(query, p.PartNumber, p.Name, p.Description, p.Unit, p.Category, p.DetectorClass)
+	if err := scanPart(row, &p); err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Part " + p.PartNumber + " already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save part: " + err.Error()})
+		return
+	}
+	p.ImageURL = blobs.URL(p.ImageKey)
+	c.JSON(http.StatusCreated, p)
+}
+
+func UpdatePart(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	var patch models.PartPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	p, err := getPart(db, c.Param("partNumber"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Part not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part"})
+		return
+	}
+
+	if patch.Name != nil {
+		p.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		p.Description = *patch.Description
+	}
+	if patch.Unit != nil {
+		p.Unit = *patch.Unit
+	}
+	if patch.Category != nil {
+		p.Category = *patch.Category
+	}
+	if patch.DetectorClass != nil {
+		p.DetectorClass = *patch.DetectorClass
+	}
+	if msg := validatePart(p); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := `
+		UPDATE parts
+		SET name = $1, description = $2, unit = $3, category = $4, detector_class = $5
+		WHERE part_number = $6
+		RETURNING ` + partColumns
+	row := db.QueryRow(query, p.Name, p.Description, p.Unit, p.Category, p.DetectorClass, p.PartNumber)
+	if err := scanPart(row, &p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update part: " + err.Error()})
+		return
+	}
+	p.ImageURL = blobs.URL(p.ImageKey)
+	c.JSON(http.StatusOK, p)
+}
+
+func DeletePart(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	p, err := getPart(db, c.Param("partNumber"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Part not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part"})
+		return
+	}
+
+	if _, err := db.Exec("DELETE FROM parts WHERE part_number = $1", p.PartNumber); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete part"})
+		return
+	}
+	if p.ImageKey != "" {
+		if err := blobs.Delete(c.Request.Context(), p.ImageKey); err != nil {
+			log.Printf("Gagal menghapus gambar part %s: %v", p.PartNumber, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Part deleted successfully"})
+}
+
+// UploadPartImage stores the reference image of a part, replacing any earlier one.
+func UploadPartImage(c *gin.Context, db *sql.DB, blobs *storage.Blobs) {
+	p, err := getPart(db, c.Param("partNumber"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Part not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file is required"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open image"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxPartImageSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+		return
+	}
+	if len(data) > maxPartImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is larger than 10 MB"})
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is not an image"})
+		return
+	}
+
+	key := fmt.Sprintf("parts/%s/%d%s", storage.KeySegment(p.PartNumber), time.Now().UnixNano(), storage.ExtensionFor(contentType))
+	if err := blobs.Put(c.Request.Context(), key, data, contentType); err != nil {
+		log.Printf("Gagal menyimpan gambar part %s: %v", p.PartNumber, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if _, err := db.Exec("UPDATE parts SET image_key = $1 WHERE part_number = $2", key, p.PartNumber); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update part"})
+		return
+	}
+	if p.ImageKey != "" {
+		if err := blobs.Delete(c.Request.Context(), p.ImageKey); err != nil {
+			log.Printf("Gagal menghapus gambar lama part %s: %v", p.PartNumber, err)
+		}
+	}
+
+	p.ImageKey = key
+	p.ImageURL = blobs.URL(key)
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,167 @@
+package part
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// maxAssemblyDepth bounds the sub-assembly walk; BOM writes already reject cycles.
+const maxAssemblyDepth = 10
+
+// GetWhereUsed lists every BOM that uses a part, directly or through
+// sub-assemblies, with the latest inspection of that BOM and its open action
+// items for the part. The part does not have to be in the catalog.
+func GetWhereUsed(c *gin.Context, db *sql.DB) {
+	partNumber := c.Param("partNumber")
+	result := models.WhereUsed{PartReference: partNumber, Usages: []models.PartUsage{}}
+
+	catalogPart, err := getPart(db, partNumber)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part"})
+		return
+	}
+	result.CatalogName = catalogPart.Name
+
+	query := `
+		WITH RECURSIVE usage(bom_code, part_name, quantity, via, depth) AS (
+			SELECT bom_code, part_name, quantity, ARRAY[]::VARCHAR[], 0
+			FROM boms WHERE part_reference = $1
+			UNION ALL
+			SELECT p.bom_code, u.part_name, u.quantity * p.quantity, ARRAY[u.bom_code]::VARCHAR[] || u.via, u.depth + 1
+			FROM usage u
+			JOIN boms p ON p.component_bom_code = u.bom_code
+			WHERE u.depth < $2
+		)
+		SELECT
+			u.bom_code, u.part_name, SUM(u.quantity), u.via,
+			dr.bom_code IS NOT NULL, COALESCE(dr.is_finalized, FALSE), dr.updated_at, dr.comparison_result_json
+		FROM usage u
+		LEFT JOIN detection_results dr ON dr.bom_code = u.bom_code
+		GROUP BY u.bom_code, u.part_name, u.via, dr.id
+		ORDER BY u.bom_code, u.via
+	`
+	rows, err := db.Query(query, partNumber, maxAssemblyDepth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part usage"})
+		return
+	}
+	defer rows.Close()
+
+	var bomCodes []string
+	for rows.Next() {
+		var u models.PartUsage
+		var hasResult, finalized bool
+		var inspectedAt *time.Time
+		var resultJSON []byte
+		if err := rows.Scan(&u.BomCode, &u.PartName, &u.Quantity, pq.Array(&u.Via), &hasResult, &finalized, &inspectedAt, &resultJSON); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan part usage"})
+			return
+		}
+		if u.Via == nil {
+			u.Via = []string{}
+		}
+		u.LastInspectedAt = inspectedAt
+		u.OpenActionItems = []models.ActionableItem{}
+
+		switch {
+		case finalized:
+			u.InspectionStatus = models.InspectionFinalized
+		case hasResult:
+			u.InspectionStatus = models.InspectionInspected
+		default:
+			u.InspectionStatus = models.InspectionNotInspected
+		}
+		if hasResult {
+			var stored models.ComparisonResult
+			if err := json.Unmarshal(resultJSON, &stored); err == nil {
+				u.PartStatus = partStatus(stored, u.PartName, result.CatalogName)
+			}
+		}
+
+		result.Usages = append(result.Usages, u)
+		bomCodes = append(bomCodes, u.BomCode)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch part usage"})
+		return
+	}
+	if len(result.Usages) == 0 {
+		if result.CatalogName == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Part not found in any BOM or in the catalog"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	items, err := db.Query(`
+		SELECT id, bom_code, part_name, item_type, quantity_diff, status, created_at, updated_at
+		FROM actionable_items
+		WHERE bom_code = ANY($1) AND status <> 'SELESAI'
+		ORDER BY created_at DESC
+	`, pq.Array(bomCodes))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch actionable items"})
+		return
+	}
+	defer items.Close()
+
+	for items.Next() {
+		var item models.ActionableItem
+		if err := items.Scan(&item.ID, &item.BomCode, &item.PartName, &item.ItemType, &item.QuantityDiff, &item.Status, &item.CreatedAt, &item.UpdatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan actionable item"})
+			return
+		}
+		for i := range result.Usages {
+			u := &result.Usages[i]
+			if u.BomCode == item.BomCode && (item.PartName == u.PartName || item.PartName == result.CatalogName) {
+				u.OpenActionItems = append(u.OpenActionItems, item)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// partStatus reports the verdict of a part in a stored comparison. Detection
+// uses the catalog name for catalog parts, so both names are checked. Results
+// stored before items had a verdict fall back to the list the part is in.
+func partStatus(result models.ComparisonResult, names ...string) string {
+	matches := func(partName string) bool {
+		for _, name := range names {
+			if name != "" && name == partName {
+				return true
+			}
+		}
+		return false
+	}
+	verdict := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	}
+	for _, item := range result.MatchedItems {
+		if matches(item.PartName) {
+			return verdict(item.Verdict, models.VerdictOK)
+		}
+	}
+	for _, item := range result.ShortageItems {
+		if matches(item.PartName) {
+			return verdict(item.Verdict, models.VerdictShort)
+		}
+	}
+	for _, item := range result.SurplusItems {
+		if matches(item.PartName) {
+			return verdict(item.Verdict, models.VerdictSurplus)
+		}
+	}
+	return models.PartNotCompared
+}
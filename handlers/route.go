@@ -4,31 +4,151 @@ import (
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
+	"yolo-server/detector"
 	"yolo-server/handlers/action"
+	"yolo-server/handlers/alias"
 	"yolo-server/handlers/bom"
 	"yolo-server/handlers/detection"
+	"yolo-server/handlers/image"
+	"yolo-server/handlers/part"
+	"yolo-server/handlers/threshold"
+	"yolo-server/handlers/tolerance"
+	"yolo-server/storage"
 )
 
-func RegisterRoutes(r *gin.RouterGroup, db *sql.DB, pythonAPI string) {
+func RegisterRoutes(r *gin.RouterGroup, db *sql.DB, det detector.Detector, blobs *storage.Blobs, jobs *detection.JobQueue) {
 	
 	// Group BOM
 	bomGroup := r.Group("/boms")
 	{
 		bomGroup.GET("", func(c *gin.Context) { bom.GetBOMs(c, db) })
 		bomGroup.POST("", func(c *gin.Context) { bom.AddBOMEntry(c, db) })
+		bomGroup.GET("/summary", func(c *gin.Context) { bom.GetBOMSummary(c, db) })
         bomGroup.POST("/upload", func(c *gin.Context) { bom.ImportBOMs(c, db) })
         bomGroup.GET("/export", func(c *gin.Context) { bom.ExportBOMs(c, db) })
 		bomGroup.POST("/batch", func(c *gin.Context) { bom.AddBOMBatch(c, db) })
+		bomGroup.DELETE("/code/:bomCode", func(c *gin.Context) { bom.DeleteBOMByCode(c, db) })
+		bomGroup.GET("/:id", func(c *gin.Context) { bom.GetBOMEntry(c, db) })
+		// gin allows one wildcard name per path segment, so routes keyed by BOM code
+		// below also use :id, which holds the bomCode there.
+		bomGroup.GET("/:id/explode", func(c *gin.Context) { bom.ExplodeBOM(c, db) })
+		bomGroup.POST("/:id/clone", func(c *gin.Context) { bom.CloneBOM(c, db) })
+		bomGroup.GET("/:id/model", func(c *gin.Context) { detection.GetBOMModel(c, db) })
+		bomGroup.POST("/:id/model", func(c *gin.Context) { detection.AssignBOMModel(c, db) })
+		bomGroup.DELETE("/:id/model", func(c *gin.Context) { detection.UnassignBOMModel(c, db) })
+		bomGroup.GET("/:id/revisions", func(c *gin.Context) { bom.GetRevisions(c, db) })
+		bomGroup.POST("/:id/revisions", func(c *gin.Context) { bom.CreateRevision(c, db) })
+		bomGroup.GET("/:id/revisions/diff", func(c *gin.Context) { bom.DiffRevisions(c, db) })
+		bomGroup.GET("/:id/revisions/:rev", func(c *gin.Context) { bom.GetRevision(c, db) })
+		bomGroup.PATCH("/:id/revisions/:rev", func(c *gin.Context) { bom.UpdateRevision(c, db) })
+		bomGroup.PATCH("/:id", func(c *gin.Context) { bom.UpdateBOMEntry(c, db) })
+		bomGroup.DELETE("/:id", func(c *gin.Context) { bom.DeleteBOMEntry(c, db) })
+	}
+
+	// Group BOM Header
+	headerGroup := r.Group("/bom-headers")
+	{
+		headerGroup.GET("", func(c *gin.Context) { bom.GetBOMHeaders(c, db) })
+		headerGroup.POST("", func(c *gin.Context) { bom.CreateBOMHeader(c, db) })
+		headerGroup.GET("/:bomCode", func(c *gin.Context) { bom.GetBOMHeader(c, db) })
+		headerGroup.PATCH("/:bomCode", func(c *gin.Context) { bom.UpdateBOMHeader(c, db) })
+		headerGroup.DELETE("/:bomCode", func(c *gin.Context) { bom.DeleteBOMHeader(c, db) })
+	}
+
+	// Group Import Profile
+	profileGroup := r.Group("/import-profiles")
+	{
+		profileGroup.GET("", func(c *gin.Context) { bom.GetImportProfiles(c, db) })
+		profileGroup.POST("", func(c *gin.Context) { bom.CreateImportProfile(c, db) })
+		profileGroup.PATCH("/:id", func(c *gin.Context) { bom.UpdateImportProfile(c, db) })
+		profileGroup.DELETE("/:id", func(c *gin.Context) { bom.DeleteImportProfile(c, db) })
+	}
+
+	// Group Part Catalog
+	partGroup := r.Group("/parts")
+	{
+		partGroup.GET("", func(c *gin.Context) { part.GetParts(c, db, blobs) })
+		partGroup.POST("", func(c *gin.Context) { part.CreatePart(c, db, blobs) })
+		partGroup.GET("/:partNumber", func(c *gin.Context) { part.GetPart(c, db, blobs) })
+		partGroup.PATCH("/:partNumber", func(c *gin.Context) { part.UpdatePart(c, db, blobs) })
+		partGroup.DELETE("/:partNumber", func(c *gin.Context) { part.DeletePart(c, db, blobs) })
+		partGroup.POST("/:partNumber/image", func(c *gin.Context) { part.UploadPartImage(c, db, blobs) })
+		partGroup.GET("/:partNumber/where-used", func(c *gin.Context) { part.GetWhereUsed(c, db) })
+	}
+
+	// Group Part Alias
+	aliasGroup := r.Group("/part-aliases")
+	{
+		aliasGroup.GET("", func(c *gin.Context) { alias.GetAliases(c, db) })
+		aliasGroup.POST("", func(c *gin.Context) { alias.CreateAlias(c, db) })
+		aliasGroup.PATCH("/:id", func(c *gin.Context) { alias.UpdateAlias(c, db) })
+		aliasGroup.DELETE("/:id", func(c *gin.Context) { alias.DeleteAlias(c, db) })
+	}
+
+	// Group Tolerance Rule
+	toleranceGroup := r.Group("/tolerance-rules")
+	{
+		toleranceGroup.GET("", func(c *gin.Context) { tolerance.GetRules(c, db) })
+		toleranceGroup.POST("", func(c *gin.Context) { tolerance.CreateRule(c, db) })
+		toleranceGroup.PATCH("/:id", func(c *gin.Context) { tolerance.UpdateRule(c, db) })
+		toleranceGroup.DELETE("/:id", func(c *gin.Context) { tolerance.DeleteRule(c, db) })
+	}
+
+	// Group Confidence Threshold
+	thresholdGroup := r.Group("/confidence-thresholds")
+	{
+		thresholdGroup.GET("", func(c *gin.Context) { threshold.GetThresholds(c, db) })
+		thresholdGroup.POST("", func(c *gin.Context) { threshold.CreateThreshold(c, db) })
+		thresholdGroup.PATCH("/:className", func(c *gin.Context) { threshold.UpdateThreshold(c, db) })
+		thresholdGroup.DELETE("/:className", func(c *gin.Context) { threshold.DeleteThreshold(c, db) })
+	}
+
+	// Group Detection Defaults
+	defaultsGroup := r.Group("/detection-defaults")
+	{
+		defaultsGroup.GET("", func(c *gin.Context) { detection.GetDetectionDefaults(c, db) })
+		defaultsGroup.POST("", func(c *gin.Context) { detection.CreateDetectionDefaults(c, db) })
+		defaultsGroup.PATCH("/:id", func(c *gin.Context) { detection.UpdateDetectionDefaults(c, db) })
+		defaultsGroup.DELETE("/:id", func(c *gin.Context) { detection.DeleteDetectionDefaults(c, db) })
+	}
+
+	// Group Detection Model Registry
+	modelGroup := r.Group("/detection-models")
+	{
+		modelGroup.GET("", func(c *gin.Context) { detection.GetDetectionModels(c, db) })
+		modelGroup.POST("", func(c *gin.Context) { detection.CreateDetectionModel(c, db, blobs) })
+		modelGroup.GET("/:id", func(c *gin.Context) { detection.GetDetectionModel(c, db) })
+		modelGroup.PATCH("/:id", func(c *gin.Context) { detection.UpdateDetectionModel(c, db) })
+		modelGroup.DELETE("/:id", func(c *gin.Context) { detection.DeleteDetectionModel(c, db, blobs) })
+		modelGroup.GET("/:id/coverage", func(c *gin.Context) { detection.GetModelCoverage(c, db) })
 	}
 
 	// Group Detection
 	detectionGroup := r.Group("/detect")
 	{
-		detectionGroup.POST("/:bomCode", func(c *gin.Context) { detection.HandleDetectAndCompare(c, db, pythonAPI) })
-		detectionGroup.GET("/:bomCode", func(c *gin.Context) { detection.GetDetectionResult(c, db) })
+		detectionGroup.POST("/:bomCode", func(c *gin.Context) { detection.HandleDetectAndCompare(c, db, det, blobs, jobs) })
+		detectionGroup.GET("/:bomCode", func(c *gin.Context) { detection.GetDetectionResult(c, db, blobs) })
+		detectionGroup.GET("/:bomCode/runs", func(c *gin.Context) { detection.GetDetectionRuns(c, db) })
+		detectionGroup.GET("/:bomCode/runs/:runId", func(c *gin.Context) { detection.GetDetectionRun(c, db, blobs) })
+		detectionGroup.POST("/:bomCode/sessions", func(c *gin.Context) { detection.CreateInspectionSession(c, db, blobs) })
+		detectionGroup.GET("/:bomCode/sessions/:sessionId", func(c *gin.Context) { detection.GetInspectionSession(c, db, blobs) })
+		detectionGroup.POST("/:bomCode/sessions/:sessionId/images", func(c *gin.Context) { detection.AddSessionImages(c, db, det, blobs) })
+		detectionGroup.POST("/:bomCode/sessions/:sessionId/close", func(c *gin.Context) { detection.CloseInspectionSession(c, db, blobs) })
+		detectionGroup.PATCH("/:bomCode/counts", func(c *gin.Context) { detection.UpdateDetectionCounts(c, db, blobs) })
+		detectionGroup.POST("/:bomCode/finalize", func(c *gin.Context) { detection.FinalizeInspection(c, db) })
+		detectionGroup.POST("/:bomCode/reopen", func(c *gin.Context) { detection.ReopenInspection(c, db) })
 		detectionGroup.DELETE("/:bomCode", func(c *gin.Context) { detection.ResetDetectionResult(c, db) })
 	}
 
+	// Group Detection Jobs
+	jobGroup := r.Group("/jobs")
+	{
+		jobGroup.GET("/:id", func(c *gin.Context) { detection.GetJob(c, db, blobs) })
+	}
+
+	// Signed image links
+	r.GET("/images/*key", func(c *gin.Context) { image.ServeImage(c, blobs) })
+
 	// Group Action Items
 	actionGroup := r.Group("/action-items")
 	{
@@ -0,0 +1,146 @@
+package threshold
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const thresholdColumns = "class_name, min_confidence, created_at, updated_at"
+
+func scanThreshold(row interface{ Scan(...any) error }, t *models.ConfidenceThreshold) error {
+	return row.Scan(&t.ClassName, &t.MinConfidence, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func validateMinConfidence(value float64) string {
+	if value < 0 || value > 1 {
+		return "minConfidence must be between 0 and 1"
+	}
+	return ""
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// LoadAll returns the minimum confidence of every class that has one.
+func LoadAll(db *sql.DB) (map[string]float64, error) {
+	rows, err := db.Query("SELECT class_name, min_confidence FROM confidence_thresholds")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thresholds := make(map[string]float64)
+	for rows.Next() {
+		var className string
+		var minConfidence float64
+		if err := rows.Scan(&className, &minConfidence); err != nil {
+			return nil, err
+		}
+		thresholds[className] = minConfidence
+	}
+	return thresholds, rows.Err()
+}
+
+func GetThresholds(c *gin.Context, db *sql.DB) {
+	rows, err := db.Query("SELECT " + thresholdColumns + " FROM confidence_thresholds ORDER BY class_name")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch confidence thresholds"})
+		return
+	}
+	defer rows.Close()
+
+	thresholds := []models.ConfidenceThreshold{}
+	for rows.Next() {
+		var t models.ConfidenceThreshold
+		if err := scanThreshold(rows, &t); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan confidence threshold"})
+			return
+		}
+		thresholds = append(thresholds, t)
+	}
+	c.JSON(http.StatusOK, thresholds)
+}
+
+func CreateThreshold(c *gin.Context, db *sql.DB) {
+	var t models.ConfidenceThreshold
+	if err := c.ShouldBindJSON(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	t.ClassName = strings.TrimSpace(t.ClassName)
+	if t.ClassName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "className is required"})
+		return
+	}
+	if msg := validateMinConfidence(t.MinConfidence); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := "INSERT INTO confidence_thresholds (class_name, min_confidence) VALUES ($1, $2) RETURNING " + thresholdColumns
+	if err := scanThreshold(db.QueryRow(query, t.ClassName, t.MinConfidence), &t); err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Class " + t.ClassName + " already has a threshold"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save confidence threshold: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+func UpdateThreshold(c *gin.Context, db *sql.DB) {
+	var patch struct {
+		MinConfidence *float64 `json:"minConfidence" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if msg := validateMinConfidence(*patch.MinConfidence); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var t models.ConfidenceThreshold
+	query := "UPDATE confidence_thresholds SET min_confidence = $1 WHERE class_name = $2 RETURNING " + thresholdColumns
+	err := scanThreshold(db.QueryRow(query, *patch.MinConfidence, c.Param("className")), &t)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Confidence threshold not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update confidence threshold: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func DeleteThreshold(c *gin.Context, db *sql.DB) {
+	result, err := db.Exec("DELETE FROM confidence_thresholds WHERE class_name = $1", c.Param("className"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete confidence threshold"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check affected rows"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Confidence threshold not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Confidence threshold deleted successfully"})
+}
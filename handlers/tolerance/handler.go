@@ -0,0 +1,204 @@
+package tolerance
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"yolo-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const ruleColumns = "id, COALESCE(bom_code, ''), COALESCE(part_reference, ''), COALESCE(part_name, ''), under_mode, under_value, over_mode, over_value, created_at, updated_at"
+
+func scanRule(row interface{ Scan(...any) error }, r *models.ToleranceRule) error {
+	return row.Scan(&r.ID, &r.BomCode, &r.PartReference, &r.PartName, &r.UnderMode, &r.Under, &r.OverMode, &r.Over, &r.CreatedAt, &r.UpdatedAt)
+}
+
+func validateMode(mode string) bool {
+	return mode == models.ToleranceAbsolute || mode == models.TolerancePercent
+}
+
+func validateRule(r models.ToleranceRule) string {
+	if !validateMode(r.UnderMode) || !validateMode(r.OverMode) {
+		return "underMode and overMode must be ABSOLUTE or PERCENT"
+	}
+	if r.Under < 0 || r.Over < 0 {
+		return "under and over must not be negative"
+	}
+	return ""
+}
+
+// isForeignKeyViolation reports whether err comes from a rule for an unknown BOM code.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// LoadForBOM returns the rules that can apply to a BOM: its own and the global ones.
+func LoadForBOM(db *sql.DB, bomCode string) ([]models.ToleranceRule, error) {
+	rows, err := db.Query("SELECT "+ruleColumns+" FROM tolerance_rules WHERE bom_code IS NULL OR bom_code = $1 ORDER BY id", bomCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.ToleranceRule
+	for rows.Next() {
+		var r models.ToleranceRule
+		if err := scanRule(rows, &r); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetRules lists tolerance rules; ?bomCode= limits the list to the rules that
+// apply to that BOM.
+func GetRules(c *gin.Context, db *sql.DB) {
+	query := "SELECT " + ruleColumns + " FROM tolerance_rules WHERE ($1 = '' OR bom_code IS NULL OR bom_code = $1) ORDER BY bom_code NULLS FIRST, part_reference, part_name, id"
+	rows, err := db.Query(query, c.Query("bomCode"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tolerance rules"})
+		return
+	}
+	defer rows.Close()
+
+	rules := []models.ToleranceRule{}
+	for rows.Next() {
+		var r models.ToleranceRule
+		if err := scanRule(rows, &r); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan tolerance rule"})
+			return
+		}
+		rules = append(rules, r)
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func CreateRule(c *gin.Context, db *sql.DB) {
+	r := models.ToleranceRule{UnderMode: models.ToleranceAbsolute, OverMode: models.ToleranceAbsolute}
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if msg := validateRule(r); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := `
+		INSERT INTO tolerance_rules (bom_code, part_reference, part_name, under_mode, under_value, over_mode, over_value)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7)
+		RETURNING ` + ruleColumns
+	row := db.QueryRow(query, r.BomCode, r.PartReference, r.PartName, r.UnderMode, r.Under, r.OverMode, r.Over)
+	if err := scanRule(row, &r); err != nil {
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "BOM " + r.BomCode + " not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tolerance rule: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+func UpdateRule(c *gin.Context, db *sql.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tolerance rule ID"})
+		return
+	}
+
+	var patch struct {
+		BomCode       *string  `json:"bomCode"`
+		PartReference *string  `json:"partReference"`
+		PartName      *string  `json:"partName"`
+		UnderMode     *string  `json:"underMode"`
+		Under         *float64 `json:"under"`
+		OverMode      *string  `json:"overMode"`
+		Over          *float64 `json:"over"`
+	}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var r models.ToleranceRule
+	err = scanRule(db.QueryRow("SELECT "+ruleColumns+" FROM tolerance_rules WHERE id = $1", id), &r)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tolerance rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tolerance rule"})
+		return
+	}
+
+	if patch.BomCode != nil {
+		r.BomCode = *patch.BomCode
+	}
+	if patch.PartReference != nil {
+		r.PartReference = *patch.PartReference
+	}
+	if patch.PartName != nil {
+		r.PartName = *patch.PartName
+	}
+	if patch.UnderMode != nil {
+		r.UnderMode = *patch.UnderMode
+	}
+	if patch.Under != nil {
+		r.Under = *patch.Under
+	}
+	if patch.OverMode != nil {
+		r.OverMode = *patch.OverMode
+	}
+	if patch.Over != nil {
+		r.Over = *patch.Over
+	}
+	if msg := validateRule(r); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	query := `
+		UPDATE tolerance_rules
+		SET bom_code = NULLIF($1, ''), part_reference = NULLIF($2, ''), part_name = NULLIF($3, ''),
+			under_mode = $4, under_value = $5, over_mode = $6, over_value = $7
+		WHERE id = $8
+		RETURNING ` + ruleColumns
+	row := db.QueryRow(query, r.BomCode, r.PartReference, r.PartName, r.UnderMode, r.Under, r.OverMode, r.Over, id)
+	if err := scanRule(row, &r); err != nil {
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "BOM " + r.BomCode + " not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tolerance rule: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func DeleteRule(c *gin.Context, db *sql.DB) {
+	result, err := db.Exec("DELETE FROM tolerance_rules WHERE id = $1", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tolerance rule"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check affected rows"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tolerance rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tolerance rule deleted successfully"})
+}
@@ -78,4 +78,408 @@ BEFORE UPDATE ON detection_results
 FOR EACH ROW
 EXECUTE FUNCTION update_updated_at_column();
 
-\echo '✅ Tabel detection_results diperbarui dengan kolom original_image.'
\ No newline at end of file
+\echo '✅ Tabel detection_results diperbarui dengan kolom original_image.'
+DROP TABLE IF EXISTS detection_runs;
+
+CREATE TABLE detection_runs (
+    id SERIAL PRIMARY KEY,
+    bom_code VARCHAR(50) NOT NULL,
+    original_image TEXT NOT NULL,
+    annotated_image TEXT NOT NULL,
+    comparison_result_json JSONB NOT NULL,
+    model_used VARCHAR(255) NOT NULL DEFAULT 'default',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_detection_runs_bom_code ON detection_runs (bom_code, created_at DESC);
+
+ALTER TABLE detection_results
+ADD COLUMN latest_run_id INTEGER REFERENCES detection_runs(id) ON DELETE SET NULL;
+
+\echo '✅ Tabel detection_runs dibuat untuk riwayat deteksi.'
+
+DROP TABLE IF EXISTS part_class_aliases;
+
+CREATE TABLE part_class_aliases (
+    id SERIAL PRIMARY KEY,
+    part_reference VARCHAR(50),
+    part_name VARCHAR(100),
+    class_name VARCHAR(100) NOT NULL,
+    loose_match BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (COALESCE(part_reference, '') <> '' OR COALESCE(part_name, '') <> '')
+);
+
+CREATE INDEX idx_part_class_aliases_reference ON part_class_aliases (part_reference);
+
+CREATE TRIGGER update_part_class_aliases_updated_at
+BEFORE UPDATE ON part_class_aliases
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+\echo '✅ Tabel part_class_aliases dibuat.'
+
+DROP TABLE IF EXISTS detection_jobs;
+DROP TYPE IF EXISTS job_status_enum;
+
+CREATE TYPE job_status_enum AS ENUM ('queued', 'running', 'succeeded', 'failed');
+
+CREATE TABLE detection_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bom_code VARCHAR(50) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    image_key TEXT NOT NULL,
+    status job_status_enum NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    run_id INTEGER REFERENCES detection_runs(id) ON DELETE SET NULL,
+    comparison_result_json JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_detection_jobs_queued ON detection_jobs (created_at) WHERE status = 'queued';
+
+\echo '✅ Tabel detection_jobs dibuat untuk antrean deteksi.'
+
+DROP TABLE IF EXISTS detection_count_overrides;
+
+CREATE TABLE detection_count_overrides (
+    id SERIAL PRIMARY KEY,
+    bom_code VARCHAR(50) NOT NULL,
+    run_id INTEGER REFERENCES detection_runs(id) ON DELETE SET NULL,
+    part_name VARCHAR(100) NOT NULL,
+    model_count INTEGER NOT NULL,
+    override_count INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    changed_by VARCHAR(100) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_detection_count_overrides_run ON detection_count_overrides (run_id);
+
+\echo '✅ Tabel detection_count_overrides dibuat untuk audit koreksi jumlah.'
+
+ALTER TABLE detection_results
+ADD COLUMN finalized_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN finalized_by VARCHAR(100),
+ADD COLUMN reopened_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN reopened_by VARCHAR(100),
+ADD COLUMN reopen_reason TEXT;
+
+\echo '✅ Kolom finalisasi ditambahkan ke detection_results.'
+
+DROP TABLE IF EXISTS bom_revisions;
+DROP TYPE IF EXISTS bom_revision_status_enum;
+
+CREATE TYPE bom_revision_status_enum AS ENUM ('DRAFT', 'RELEASED');
+
+-- A revision is a frozen copy of the lines of a BOM. Detection compares against the
+-- released revision in effect, or against the current lines in boms when there is none.
+CREATE TABLE bom_revisions (
+    id SERIAL PRIMARY KEY,
+    bom_code VARCHAR(50) NOT NULL,
+    revision INTEGER NOT NULL,
+    status bom_revision_status_enum NOT NULL DEFAULT 'DRAFT',
+    effective_from TIMESTAMP WITH TIME ZONE,
+    effective_to TIMESTAMP WITH TIME ZONE,
+    lines JSONB NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (bom_code, revision),
+    CHECK (effective_to IS NULL OR effective_from IS NULL OR effective_to > effective_from)
+);
+
+ALTER TABLE detection_runs
+ADD COLUMN bom_revision INTEGER;
+
+\echo '✅ Tabel bom_revisions dibuat.'
+
+-- A line with component_bom_code refers to another BOM used as a sub-assembly;
+-- its quantity is the number of sub-assemblies.
+ALTER TABLE boms
+ADD COLUMN component_bom_code VARCHAR(50);
+
+CREATE INDEX idx_boms_component_bom_code ON boms (component_bom_code) WHERE component_bom_code IS NOT NULL;
+
+ALTER TABLE detection_jobs
+ADD COLUMN bom_view VARCHAR(10) NOT NULL DEFAULT 'top';
+
+\echo '✅ Kolom component_bom_code ditambahkan ke boms.'
+
+-- Lines are identified by their part reference within a BOM, which import
+-- upserts rely on. Lines without a reference are matched by name instead.
+CREATE UNIQUE INDEX uq_boms_code_part_reference ON boms (bom_code, part_reference) WHERE part_reference <> '';
+
+\echo '✅ Unique index (bom_code, part_reference) dibuat.'
+
+DROP TABLE IF EXISTS import_profiles;
+
+-- Saved options for BOM files from one ERP system; columns maps an import
+-- column (e.g. "quantity") to the header name used in that system's files.
+CREATE TABLE import_profiles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    delimiter VARCHAR(1) NOT NULL DEFAULT '',
+    encoding VARCHAR(20) NOT NULL DEFAULT '',
+    sheet VARCHAR(100) NOT NULL DEFAULT '',
+    columns JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_import_profiles_updated_at
+BEFORE UPDATE ON import_profiles
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+\echo '✅ Tabel import_profiles dibuat.'
+
+DROP TABLE IF EXISTS bom_headers CASCADE;
+
+-- One row per BOM code with descriptive metadata; boms rows are its lines.
+CREATE TABLE bom_headers (
+    bom_code VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(150) NOT NULL DEFAULT '',
+    product_code VARCHAR(50) NOT NULL DEFAULT '',
+    customer VARCHAR(100) NOT NULL DEFAULT '',
+    production_line VARCHAR(100) NOT NULL DEFAULT '',
+    owner VARCHAR(100) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO bom_headers (bom_code)
+SELECT DISTINCT bom_code FROM boms;
+
+ALTER TABLE boms
+ADD CONSTRAINT fk_boms_bom_header FOREIGN KEY (bom_code) REFERENCES bom_headers(bom_code) ON UPDATE CASCADE;
+
+CREATE TRIGGER update_bom_headers_updated_at
+BEFORE UPDATE ON bom_headers
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+\echo '✅ Tabel bom_headers dibuat.'
+
+DROP TABLE IF EXISTS parts;
+
+-- Parts catalog. BOM lines refer to a part by using its part_number as their
+-- part_reference; detector_class maps the part to a detector class.
+CREATE TABLE parts (
+    part_number VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    unit VARCHAR(20) NOT NULL DEFAULT 'pcs',
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    detector_class VARCHAR(100) NOT NULL DEFAULT '',
+    image_key TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_parts_category ON parts (category);
+CREATE INDEX idx_boms_part_reference ON boms (part_reference);
+
+CREATE TRIGGER update_parts_updated_at
+BEFORE UPDATE ON parts
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+\echo '✅ Tabel parts (katalog part) dibuat.'
+
+-- Parts that may stand in for the part of a line, as a JSON array of
+-- {partReference, partName, priority}; lower priorities are counted first.
+ALTER TABLE boms
+ADD COLUMN alternates JSONB NOT NULL DEFAULT '[]';
+
+\echo '✅ Kolom alternates ditambahkan ke boms.'
+
+DROP TABLE IF EXISTS tolerance_rules;
+
+-- Accepted differences between detected and required quantities. A NULL
+-- bom_code applies to every BOM; NULL part_reference and part_name apply to
+-- every part. under_value and over_value are pieces (ABSOLUTE) or a
+-- percentage of the required quantity (PERCENT).
+CREATE TABLE tolerance_rules (
+    id SERIAL PRIMARY KEY,
+    bom_code VARCHAR(50) REFERENCES bom_headers(bom_code) ON UPDATE CASCADE ON DELETE CASCADE,
+    part_reference VARCHAR(50),
+    part_name VARCHAR(100),
+    under_mode VARCHAR(10) NOT NULL DEFAULT 'ABSOLUTE' CHECK (under_mode IN ('ABSOLUTE', 'PERCENT')),
+    under_value NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK (under_value >= 0),
+    over_mode VARCHAR(10) NOT NULL DEFAULT 'ABSOLUTE' CHECK (over_mode IN ('ABSOLUTE', 'PERCENT')),
+    over_value NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK (over_value >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_tolerance_rules_bom_code ON tolerance_rules (bom_code);
+
+CREATE TRIGGER update_tolerance_rules_updated_at
+BEFORE UPDATE ON tolerance_rules
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+\echo '✅ Tabel tolerance_rules dibuat.'
+
+DROP TABLE IF EXISTS confidence_thresholds;
+
+-- Minimum average detector confidence per class; matching counts below it are
+-- marked NEEDS_REVIEW instead of OK.
+CREATE TABLE confidence_thresholds (
+    class_name VARCHAR(100) PRIMARY KEY,
+    min_confidence NUMERIC(4, 3) NOT NULL CHECK (min_confidence BETWEEN 0 AND 1),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_confidence_thresholds_updated_at
+BEFORE UPDATE ON confidence_thresholds
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+\echo '✅ Tabel confidence_thresholds dibuat.'
+
+DROP TABLE IF EXISTS detection_defaults;
+
+-- Predictor parameters used when a detection request leaves them out, either
+-- for one BOM or for a part family (a parts catalog category). NULL keeps the
+-- predictor's own default. BOM defaults win over family defaults.
+CREATE TABLE detection_defaults (
+    id SERIAL PRIMARY KEY,
+    bom_code VARCHAR(50) UNIQUE REFERENCES bom_headers(bom_code) ON UPDATE CASCADE ON DELETE CASCADE,
+    category VARCHAR(100) UNIQUE,
+    conf NUMERIC(4, 3) CHECK (conf > 0 AND conf <= 1),
+    iou NUMERIC(4, 3) CHECK (iou > 0 AND iou <= 1),
+    agnostic_nms BOOLEAN,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((bom_code IS NULL) <> (category IS NULL))
+);
+
+CREATE TRIGGER update_detection_defaults_updated_at
+BEFORE UPDATE ON detection_defaults
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Parameters a queued job runs with, and the blob key of custom weights sent along.
+ALTER TABLE detection_jobs
+ADD COLUMN params JSONB NOT NULL DEFAULT '{}',
+ADD COLUMN model_key TEXT NOT NULL DEFAULT '';
+
+\echo '✅ Tabel detection_defaults dibuat.'
+
+DROP TABLE IF EXISTS bom_model_assignments;
+DROP TABLE IF EXISTS detection_models;
+
+-- Registry of YOLO weights. The weights live in blob storage under weights_key;
+-- classes are the class names the model can detect. At most one model is the default.
+CREATE TABLE detection_models (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    classes TEXT[] NOT NULL DEFAULT '{}',
+    notes TEXT NOT NULL DEFAULT '',
+    file_name VARCHAR(255) NOT NULL,
+    weights_key TEXT NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (name, version)
+);
+
+CREATE UNIQUE INDEX idx_detection_models_default ON detection_models (is_default) WHERE is_default;
+
+CREATE TRIGGER update_detection_models_updated_at
+BEFORE UPDATE ON detection_models
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- The model detections of a BOM use instead of the default one.
+CREATE TABLE bom_model_assignments (
+    bom_code VARCHAR(50) PRIMARY KEY REFERENCES bom_headers(bom_code) ON UPDATE CASCADE ON DELETE CASCADE,
+    model_id INTEGER NOT NULL REFERENCES detection_models(id) ON DELETE CASCADE,
+    assigned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_bom_model_assignments_model_id ON bom_model_assignments (model_id);
+
+\echo '✅ Tabel detection_models dan bom_model_assignments dibuat.'
+
+DROP TABLE IF EXISTS detection_run_images;
+
+-- Every photo of a run in order, with what the predictor saw in it. The run
+-- itself keeps the first photo in original_image and annotated_image.
+CREATE TABLE detection_run_images (
+    id SERIAL PRIMARY KEY,
+    run_id INTEGER NOT NULL REFERENCES detection_runs(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    original_image TEXT NOT NULL,
+    annotated_image TEXT NOT NULL DEFAULT '',
+    detections JSONB NOT NULL DEFAULT '[]',
+    UNIQUE (run_id, position)
+);
+
+-- Queued jobs with several photos list them all; file_name and image_key keep the first.
+ALTER TABLE detection_jobs
+ADD COLUMN images JSONB NOT NULL DEFAULT '[]',
+ADD COLUMN count_strategy VARCHAR(10) NOT NULL DEFAULT 'sum';
+
+DROP TABLE IF EXISTS inspection_session_images;
+DROP TABLE IF EXISTS inspection_sessions;
+
+-- An inspection photographed in several steps; closing it stores a detection run.
+CREATE TABLE inspection_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    bom_code VARCHAR(50) NOT NULL REFERENCES bom_headers(bom_code) ON UPDATE CASCADE ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'CLOSED')),
+    count_strategy VARCHAR(10) NOT NULL DEFAULT 'sum' CHECK (count_strategy IN ('sum', 'max')),
+    bom_view VARCHAR(10) NOT NULL DEFAULT 'top',
+    params JSONB NOT NULL DEFAULT '{}',
+    run_id INTEGER REFERENCES detection_runs(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_inspection_sessions_bom_code ON inspection_sessions (bom_code, created_at DESC);
+
+CREATE TRIGGER update_inspection_sessions_updated_at
+BEFORE UPDATE ON inspection_sessions
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE inspection_session_images (
+    id SERIAL PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES inspection_sessions(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    original_image TEXT NOT NULL,
+    annotated_image TEXT NOT NULL DEFAULT '',
+    detections JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_inspection_session_images_session ON inspection_session_images (session_id, id);
+
+\echo '✅ Tabel detection_run_images dan inspection_sessions dibuat untuk inspeksi multi-gambar.'
+
+-- A registry model used by default for a BOM or a part family, when the BOM has
+-- no model assigned.
+ALTER TABLE detection_defaults
+ADD COLUMN model_id INTEGER REFERENCES detection_models(id) ON DELETE SET NULL;
+
+\echo '✅ Kolom model_id ditambahkan ke detection_defaults.'
+
+-- Action items created by finalizing an inspection record the run they come from,
+-- so finalizing again replaces only those and not the ones reported by hand.
+ALTER TABLE actionable_items
+ADD COLUMN run_id INTEGER REFERENCES detection_runs(id) ON DELETE SET NULL;
+
+\echo '✅ Kolom run_id ditambahkan ke actionable_items.'
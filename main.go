@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"yolo-server/db"
+	"yolo-server/detector"
 	"yolo-server/handlers"
+	"yolo-server/handlers/detection"
+	"yolo-server/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -23,22 +30,49 @@ func main() {
 	password := getEnv("POSTGRES_PASSWORD", "password")
 	dbname := getEnv("POSTGRES_DB", "yolo_db")
 	pythonApiUrl := getEnv("PYTHON_API_URL", "http://localhost:5001/predict")
+	detectorKind := getEnv("DETECTOR", "http")
+	detectorFixtures := getEnv("DETECTOR_FIXTURES", "./fixtures/detector")
 	port := getEnv("PORT", "8081")
+	workers, err := strconv.Atoi(getEnv("DETECTION_WORKERS", "2"))
+	if err != nil {
+		log.Fatalf("❌ DETECTION_WORKERS tidak valid: %v", err)
+	}
+	imageURLTTL, err := time.ParseDuration(getEnv("IMAGE_URL_TTL", "15m"))
+	if err != nil {
+		log.Fatalf("❌ IMAGE_URL_TTL tidak valid: %v", err)
+	}
 
 	database := db.ConnectDB(host, user, password, dbname)
 	defer database.Close()
 	fmt.Println("✅ Connected to PostgreSQL")
 
+	store, err := storage.New(context.Background(), storage.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan penyimpanan gambar: %v", err)
+	}
+	blobs := &storage.Blobs{
+		Store:  store,
+		Signer: &storage.URLSigner{BasePath: "/api/images", Secret: imageURLSecret(), TTL: imageURLTTL},
+	}
+
+	det, err := detector.New(detectorKind, pythonApiUrl, detectorFixtures)
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan detector: %v", err)
+	}
+
+	jobs := detection.NewJobQueue(database, det, blobs, workers)
+	if err := jobs.Start(context.Background()); err != nil {
+		log.Fatalf("❌ Gagal menjalankan antrean deteksi: %v", err)
+	}
+
 	router := gin.Default()
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"}
 	router.Use(cors.New(config))
 
-	router.Static("/uploads", "./uploads")
-
 	api := router.Group("/api")
-	handlers.RegisterRoutes(api, database, pythonApiUrl)
+	handlers.RegisterRoutes(api, database, det, blobs, jobs)
 
 	fmt.Printf("🚀 Go API running at http://localhost:%s\n", port)
 	router.Run(":" + port)
@@ -50,3 +84,17 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// imageURLSecret returns the key for signing image links. Without IMAGE_URL_SECRET a
+// random key is used, so links stop working after a restart.
+func imageURLSecret() []byte {
+	if secret := os.Getenv("IMAGE_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Println("⚠️  IMAGE_URL_SECRET kosong, link gambar hanya berlaku sampai server restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("❌ Gagal membuat secret link gambar: %v", err)
+	}
+	return secret
+}
@@ -9,14 +9,229 @@ type BOMEntry struct {
 	PartName        string `json:"partName"`
 	PartDescription string `json:"partDescription"`
 	Quantity        int    `json:"quantity"`
+	// ComponentBomCode makes the line a sub-assembly: Quantity units of that BOM.
+	ComponentBomCode string `json:"componentBomCode,omitempty"`
+	// Alternates may make up for a shortage of the part, see BOMAlternate.
+	Alternates []BOMAlternate `json:"alternates,omitempty"`
+}
+
+// BOMAlternate is an interchangeable part for a BOM line, such as the same part
+// from a second approved vendor. When too few of the primary part are detected,
+// alternates are counted toward the line in order of Priority, lowest first.
+type BOMAlternate struct {
+	PartReference string `json:"partReference"`
+	PartName      string `json:"partName"`
+	Priority      int    `json:"priority"`
+}
+
+type BOMEntryPatch struct {
+	BomCode          *string         `json:"bomCode"`
+	PartReference    *string         `json:"partReference"`
+	PartName         *string         `json:"partName"`
+	PartDescription  *string         `json:"partDescription"`
+	Quantity         *int            `json:"quantity"`
+	ComponentBomCode *string         `json:"componentBomCode"`
+	Alternates       *[]BOMAlternate `json:"alternates"`
 }
 
 type BOMEntryWithStatus struct {
-	BOMEntry          
+	BOMEntry
 	HasDetectionResult bool `json:"hasDetectionResult"`
 	IsFinalized        bool `json:"isFinalized"`
 }
 
+// BOM views a detection can compare against: the top-level lines as written,
+// or the leaf parts with sub-assemblies exploded.
+const (
+	BOMViewTop      = "top"
+	BOMViewExploded = "exploded"
+)
+
+// BOMHeader describes a BOM as a whole; its lines are the BOMEntry rows with
+// the same BomCode. LineCount is filled in by the API and not stored.
+type BOMHeader struct {
+	BomCode        string    `json:"bomCode"`
+	Name           string    `json:"name"`
+	ProductCode    string    `json:"productCode"`
+	Customer       string    `json:"customer"`
+	ProductionLine string    `json:"productionLine"`
+	Owner          string    `json:"owner"`
+	Description    string    `json:"description"`
+	Tags           []string  `json:"tags"`
+	LineCount      int       `json:"lineCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type BOMHeaderPatch struct {
+	Name           *string   `json:"name"`
+	ProductCode    *string   `json:"productCode"`
+	Customer       *string   `json:"customer"`
+	ProductionLine *string   `json:"productionLine"`
+	Owner          *string   `json:"owner"`
+	Description    *string   `json:"description"`
+	Tags           *[]string `json:"tags"`
+}
+
+// BOMCloneRequest copies a BOM to TargetBomCode. Quantities are scaled by
+// Multiplier (1 when omitted). Overrides replace the matching line, by part
+// reference or by part name, or add a new line; their empty fields and zero
+// quantity keep the (scaled) source value. Removals name the part references or
+// part names of lines to leave out.
+type BOMCloneRequest struct {
+	TargetBomCode string     `json:"targetBomCode"`
+	Multiplier    float64    `json:"multiplier"`
+	Overrides     []BOMEntry `json:"overrides"`
+	Removals      []string   `json:"removals"`
+}
+
+// BOMPage is one page of BOM lines; NextCursor is empty on the last page.
+type BOMPage struct {
+	Items      []BOMEntryWithStatus `json:"items"`
+	Total      int                  `json:"total"`
+	NextCursor string               `json:"nextCursor"`
+}
+
+const (
+	InspectionNotInspected = "NOT_INSPECTED"
+	InspectionInspected    = "INSPECTED"
+	InspectionFinalized    = "FINALIZED"
+)
+
+// PartNotCompared is the status of a part missing from a stored comparison.
+const PartNotCompared = "NOT_COMPARED"
+
+// BOMSummary is one BOM code with its line totals and inspection status.
+// ShortageCount and SurplusCount come from the latest detection result.
+type BOMSummary struct {
+	BomCode            string     `json:"bomCode"`
+	LineCount          int        `json:"lineCount"`
+	TotalQuantity      int        `json:"totalQuantity"`
+	HasDetectionResult bool       `json:"hasDetectionResult"`
+	IsFinalized        bool       `json:"isFinalized"`
+	InspectionStatus   string     `json:"inspectionStatus"`
+	ShortageCount      int        `json:"shortageCount"`
+	SurplusCount       int        `json:"surplusCount"`
+	LastInspectedAt    *time.Time `json:"lastInspectedAt"`
+}
+
+const (
+	RevisionDraft    = "DRAFT"
+	RevisionReleased = "RELEASED"
+)
+
+type BOMRevision struct {
+	ID            int        `json:"id"`
+	BomCode       string     `json:"bomCode"`
+	Revision      int        `json:"revision"`
+	Status        string     `json:"status"`
+	EffectiveFrom *time.Time `json:"effectiveFrom"`
+	EffectiveTo   *time.Time `json:"effectiveTo"`
+	Note          string     `json:"note"`
+	LineCount     int        `json:"lineCount"`
+	Lines         []BOMEntry `json:"lines,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ReleasedAt    *time.Time `json:"releasedAt"`
+}
+
+// BOMRevisionPatch changes a revision. Lines replace the lines of a draft;
+// released revisions are frozen.
+type BOMRevisionPatch struct {
+	Status        *string     `json:"status"`
+	EffectiveFrom *time.Time  `json:"effectiveFrom"`
+	EffectiveTo   *time.Time  `json:"effectiveTo"`
+	Note          *string     `json:"note"`
+	Lines         *[]BOMEntry `json:"lines"`
+}
+
+// BOMLineDiff describes how one line differs between two revisions.
+// Change is ADDED, REMOVED, CHANGED or UNCHANGED.
+type BOMLineDiff struct {
+	PartReference string    `json:"partReference"`
+	PartName      string    `json:"partName"`
+	Change        string    `json:"change"`
+	ChangedFields []string  `json:"changedFields,omitempty"`
+	From          *BOMEntry `json:"from,omitempty"`
+	To            *BOMEntry `json:"to,omitempty"`
+}
+
+const (
+	ImportRowAccepted = "accepted"
+	ImportRowSkipped  = "skipped"
+	ImportRowError    = "error"
+)
+
+// Import modes: append only adds new lines, upsert also updates lines with the
+// same part reference, and replace additionally deletes lines missing from the file.
+const (
+	ImportModeAppend  = "append"
+	ImportModeUpsert  = "upsert"
+	ImportModeReplace = "replace"
+)
+
+const (
+	ImportActionInserted  = "inserted"
+	ImportActionUpdated   = "updated"
+	ImportActionUnchanged = "unchanged"
+)
+
+// ImportRowResult reports what happened to one data row of an import file.
+// Row is the line number in the file, counting the header as line 1.
+type ImportRowResult struct {
+	Row            int           `json:"row"`
+	Status         string        `json:"status"`
+	Reason         string        `json:"reason,omitempty"`
+	BomCode        string        `json:"bomCode,omitempty"`
+	PartReference  string        `json:"partReference,omitempty"`
+	PartName       string        `json:"partName,omitempty"`
+	Action         string        `json:"action,omitempty"`
+	DuplicateOfRow int           `json:"duplicateOfRow,omitempty"`
+	ExistingID     int           `json:"existingId,omitempty"`
+	Catalog        *CatalogMatch `json:"catalog,omitempty"`
+}
+
+// ImportBOMSummary counts the changes an import made to one BOM code.
+type ImportBOMSummary struct {
+	BomCode   string `json:"bomCode"`
+	Inserted  int    `json:"inserted"`
+	Updated   int    `json:"updated"`
+	Deleted   int    `json:"deleted"`
+	Unchanged int    `json:"unchanged"`
+	Skipped   int    `json:"skipped"`
+}
+
+type ImportReport struct {
+	Mode      string             `json:"mode"`
+	Profile   string             `json:"profile,omitempty"`
+	Format    string             `json:"format"`
+	Sheet     string             `json:"sheet,omitempty"`
+	Delimiter string             `json:"delimiter,omitempty"`
+	Encoding  string             `json:"encoding,omitempty"`
+	DryRun    bool               `json:"dryRun"`
+	Strict    bool               `json:"strict"`
+	Committed bool               `json:"committed"`
+	Total     int                `json:"total"`
+	Accepted  int                `json:"accepted"`
+	Skipped   int                `json:"skipped"`
+	Errors    int                `json:"errors"`
+	Summary   []ImportBOMSummary `json:"summary"`
+	Rows      []ImportRowResult  `json:"rows"`
+}
+
+// ImportProfile is a saved set of import options for files from one source.
+// Columns maps an import column such as "quantity" to the header used in the
+// file. An empty Delimiter or Encoding means it is detected from the file.
+type ImportProfile struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	Delimiter string            `json:"delimiter"`
+	Encoding  string            `json:"encoding"`
+	Sheet     string            `json:"sheet"`
+	Columns   map[string]string `json:"columns"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
 type DetectionSummary struct {
 	ClassName     string  `json:"class_name"`
 	Quantity      int     `json:"quantity"`
@@ -28,26 +243,362 @@ type PythonResponse struct {
 	AnnotatedImage string             `json:"annotated_image"`
 }
 
+// Verdicts of a part in a comparison. WITHIN_TOLERANCE differs from the BOM by
+// no more than its tolerance rule allows; UNLISTED is detected but not in the BOM.
+// NEEDS_REVIEW has an accepted count but was detected with low confidence.
+const (
+	VerdictOK              = "OK"
+	VerdictWithinTolerance = "WITHIN_TOLERANCE"
+	VerdictNeedsReview     = "NEEDS_REVIEW"
+	VerdictShort           = "SHORT"
+	VerdictSurplus         = "SURPLUS"
+	VerdictUnlisted        = "UNLISTED"
+)
+
+// Overall verdicts of a comparison: PASS when every part is OK or within
+// tolerance, NEEDS_REVIEW when some of those parts still need a second look.
+const (
+	ComparisonPass        = "PASS"
+	ComparisonNeedsReview = "NEEDS_REVIEW"
+	ComparisonFail        = "FAIL"
+)
+
+// ModelDetected is set on shortage and surplus items whose detected count was
+// overridden by an inspector and holds what the model originally counted.
+// Confidence is the average detector confidence of the counted detections,
+// weighted by quantity, and LowConfidence is set when one of their classes
+// is below its minimum confidence.
 type ShortageItem struct {
-	PartName string `json:"partName"`
-	Required int    `json:"required"`
-	Detected int    `json:"detected"`
-	Shortage int    `json:"shortage"`
+	PartName      string   `json:"partName"`
+	Required      int      `json:"required"`
+	Detected      int      `json:"detected"`
+	Shortage      int      `json:"shortage"`
+	Verdict       string   `json:"verdict"`
+	Confidence    *float64 `json:"confidence,omitempty"`
+	LowConfidence bool     `json:"lowConfidence,omitempty"`
+	ModelDetected *int     `json:"modelDetected,omitempty"`
 }
 
 type SurplusItem struct {
-	PartName string `json:"partName"`
-	Detected int    `json:"detected"`
-	Required int    `json:"required"`
-	Surplus  int    `json:"surplus"`
+	PartName      string   `json:"partName"`
+	Detected      int      `json:"detected"`
+	Required      int      `json:"required"`
+	Surplus       int      `json:"surplus"`
+	Verdict       string   `json:"verdict"`
+	Confidence    *float64 `json:"confidence,omitempty"`
+	LowConfidence bool     `json:"lowConfidence,omitempty"`
+	ModelDetected *int     `json:"modelDetected,omitempty"`
 }
 
+// MatchedItem is a BOM part whose detected quantity is accepted, exactly or
+// within the tolerance rule ToleranceRuleID.
+type MatchedItem struct {
+	PartName        string   `json:"partName"`
+	Required        int      `json:"required"`
+	Detected        int      `json:"detected"`
+	Verdict         string   `json:"verdict"`
+	ToleranceRuleID int      `json:"toleranceRuleId,omitempty"`
+	Confidence      *float64 `json:"confidence,omitempty"`
+	LowConfidence   bool     `json:"lowConfidence,omitempty"`
+	ModelDetected   *int     `json:"modelDetected,omitempty"`
+}
+
+// ConfidenceThreshold is the lowest average confidence at which detections of
+// a class are trusted without review.
+type ConfidenceThreshold struct {
+	ClassName     string    `json:"className"`
+	MinConfidence float64   `json:"minConfidence"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Tolerance modes: a number of pieces, or a percentage of the required quantity.
+const (
+	ToleranceAbsolute = "ABSOLUTE"
+	TolerancePercent  = "PERCENT"
+)
+
+// ToleranceRule lets a detected quantity fall short of the BOM by Under, or
+// exceed it by Over, and still be accepted. BomCode limits the rule to one BOM
+// and PartReference or PartName to one part. For each part the most specific
+// rule applies: a part rule before a rule for all parts, and within those a
+// rule of the BOM before a global one.
+type ToleranceRule struct {
+	ID            int       `json:"id"`
+	BomCode       string    `json:"bomCode"`
+	PartReference string    `json:"partReference"`
+	PartName      string    `json:"partName"`
+	UnderMode     string    `json:"underMode"`
+	Under         float64   `json:"under"`
+	OverMode      string    `json:"overMode"`
+	Over          float64   `json:"over"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// CountOverride is an inspector's correction of the detected quantity of one part.
+type CountOverride struct {
+	PartName      string    `json:"partName"`
+	ModelCount    int       `json:"modelCount"`
+	OverrideCount int       `json:"overrideCount"`
+	Reason        string    `json:"reason"`
+	ChangedBy     string    `json:"changedBy"`
+	ChangedAt     time.Time `json:"changedAt"`
+}
+
+type CountOverrideRequest struct {
+	ChangedBy string `json:"changedBy" binding:"required"`
+	Overrides []struct {
+		PartName string `json:"partName" binding:"required"`
+		Quantity int    `json:"quantity"`
+		Reason   string `json:"reason" binding:"required"`
+	} `json:"overrides" binding:"required,min=1,dive"`
+}
+
+// ComparisonResult is the outcome of comparing a BOM with what the detector saw.
+// BomRevision is the revision compared against, nil meaning the current lines,
+// and BomView says whether sub-assemblies were exploded into leaf parts.
+// Detections is the raw model output, kept so the comparison can be recomputed.
+// Verdict is PASS, NEEDS_REVIEW or FAIL; results stored before verdicts existed
+// leave it empty.
 type ComparisonResult struct {
-	ShortageItems  []ShortageItem `json:"shortageItems"`
-	SurplusItems   []SurplusItem  `json:"surplusItems"`
-	OriginalImage  string         `json:"originalImage"`
-	AnnotatedImage string         `json:"annotatedImage"`
-	IsFinalized    bool           `json:"isFinalized"`
+	Verdict        string             `json:"verdict"`
+	MatchedItems   []MatchedItem      `json:"matchedItems"`
+	ShortageItems  []ShortageItem     `json:"shortageItems"`
+	SurplusItems   []SurplusItem      `json:"surplusItems"`
+	OriginalImage  string             `json:"originalImage"`
+	AnnotatedImage string             `json:"annotatedImage"`
+	IsFinalized    bool               `json:"isFinalized"`
+	RunID          int                `json:"runId,omitempty"`
+	BomRevision    *int               `json:"bomRevision"`
+	BomView        string             `json:"bomView"`
+	UnmappedParts  []string           `json:"unmappedParts"`
+	Detections     []DetectionSummary `json:"detections"`
+	CountOverrides []CountOverride    `json:"countOverrides,omitempty"`
+	AlternatesUsed []AlternateUsage   `json:"alternatesUsed,omitempty"`
+	// UncoveredParts are the parts the registry model the detection ran with
+	// cannot detect, so they can only come out short.
+	UncoveredParts []UncoveredPart `json:"uncoveredParts,omitempty"`
+	// DetectionParams are the predictor settings the detection ran with.
+	DetectionParams *DetectionParams `json:"detectionParams,omitempty"`
+	// CountStrategy tells how the counts of several images were combined into Detections.
+	CountStrategy string `json:"countStrategy,omitempty"`
+	// Images are the photos of the inspection; they are kept with the run, not in this JSON.
+	Images []InspectionImage `json:"images,omitempty"`
+}
+
+// Count strategies combine per-class counts across the images of one inspection:
+// sum adds them up for disjoint views, max keeps the highest for overlapping views.
+const (
+	CountStrategySum = "sum"
+	CountStrategyMax = "max"
+)
+
+// InspectionImage is one photo of an inspection and what the predictor saw in it.
+type InspectionImage struct {
+	FileName       string             `json:"fileName"`
+	OriginalImage  string             `json:"originalImage"`
+	AnnotatedImage string             `json:"annotatedImage"`
+	Detections     []DetectionSummary `json:"detections"`
+}
+
+const (
+	SessionOpen   = "OPEN"
+	SessionClosed = "CLOSED"
+)
+
+// InspectionSession collects the photos of one inspection before it is stored as
+// a run. While the session is open, Comparison previews the images added so far;
+// closing it stores the run named by RunID.
+type InspectionSession struct {
+	ID            string            `json:"id"`
+	BomCode       string            `json:"bomCode"`
+	Status        string            `json:"status"`
+	CountStrategy string            `json:"countStrategy"`
+	BomView       string            `json:"bomView"`
+	Params        DetectionParams   `json:"params"`
+	RunID         *int              `json:"runId,omitempty"`
+	Images        []InspectionImage `json:"images"`
+	Comparison    *ComparisonResult `json:"comparison,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	ClosedAt      *time.Time        `json:"closedAt,omitempty"`
+}
+
+// DetectionParams tune the predictor. Unset fields leave the predictor's own
+// defaults in place (conf 0.25, iou 0.7, no agnostic NMS). Model is the file
+// name of custom weights sent along with the image.
+type DetectionParams struct {
+	Conf        *float64 `json:"conf,omitempty"`
+	IoU         *float64 `json:"iou,omitempty"`
+	AgnosticNMS *bool    `json:"agnosticNms,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	// ModelID is set when the weights come from the model registry.
+	ModelID *int `json:"modelId,omitempty"`
+}
+
+// DetectionModel is a set of YOLO weights in the model registry. Classes are
+// the class names the weights can detect.
+type DetectionModel struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	Classes    []string  `json:"classes"`
+	Notes      string    `json:"notes"`
+	FileName   string    `json:"fileName"`
+	WeightsKey string    `json:"-"`
+	IsDefault  bool      `json:"isDefault"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// UncoveredPart is a BOM part none of whose classes a model can detect.
+type UncoveredPart struct {
+	PartName string   `json:"partName"`
+	Classes  []string `json:"classes"`
+}
+
+// ModelCoverage tells whether a model can detect every part of a BOM, either
+// as the part itself or as one of its alternates.
+type ModelCoverage struct {
+	BomCode        string          `json:"bomCode"`
+	ModelID        int             `json:"modelId"`
+	View           string          `json:"view"`
+	Covered        bool            `json:"covered"`
+	UncoveredParts []UncoveredPart `json:"uncoveredParts"`
+}
+
+// BOMModel is the model detections of a BOM use: the one assigned to it or,
+// when Assigned is false, the model of its detection defaults, of its part
+// family's, or the registry default. Model is nil when there is none and the
+// predictor's bundled weights are used.
+type BOMModel struct {
+	BomCode    string          `json:"bomCode"`
+	Assigned   bool            `json:"assigned"`
+	AssignedAt *time.Time      `json:"assignedAt,omitempty"`
+	Model      *DetectionModel `json:"model"`
+	Coverage   *ModelCoverage  `json:"coverage,omitempty"`
+}
+
+// DetectionDefaults are the detection parameters used when a request leaves
+// them out, for one BOM or for one part family (a catalog category). Exactly
+// one of BomCode and Category is set. ModelID names a registry model to use
+// when the BOM has none assigned.
+type DetectionDefaults struct {
+	ID          int       `json:"id"`
+	BomCode     string    `json:"bomCode"`
+	Category    string    `json:"category"`
+	Conf        *float64  `json:"conf"`
+	IoU         *float64  `json:"iou"`
+	AgnosticNMS *bool     `json:"agnosticNms"`
+	ModelID     *int      `json:"modelId"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// AlternateUsage is a quantity of an alternate part counted toward a BOM part.
+type AlternateUsage struct {
+	PartName  string `json:"partName"`
+	Alternate string `json:"alternate"`
+	ClassName string `json:"className"`
+	Quantity  int    `json:"quantity"`
+}
+
+type DetectionRun struct {
+	ID            int               `json:"id"`
+	BomCode       string            `json:"bomCode"`
+	ModelUsed     string            `json:"modelUsed"`
+	BomRevision   *int              `json:"bomRevision"`
+	ShortageCount int               `json:"shortageCount"`
+	SurplusCount  int               `json:"surplusCount"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	Result        *ComparisonResult `json:"result,omitempty"`
+}
+
+// Part is an entry of the parts catalog. BOM lines refer to it by using the
+// part number as their part reference. DetectorClass, when set, is the class
+// the detector reports for this part.
+type Part struct {
+	PartNumber    string    `json:"partNumber"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Unit          string    `json:"unit"`
+	Category      string    `json:"category"`
+	DetectorClass string    `json:"detectorClass"`
+	ImageKey      string    `json:"-"`
+	ImageURL      string    `json:"imageUrl"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type PartPatch struct {
+	Name          *string `json:"name"`
+	Description   *string `json:"description"`
+	Unit          *string `json:"unit"`
+	Category      *string `json:"category"`
+	DetectorClass *string `json:"detectorClass"`
+}
+
+// PartUsage is one BOM that uses a part. Via lists the sub-assemblies between
+// the BOM and the line holding the part, outermost first; it is empty when the
+// BOM lists the part itself. PartStatus is the verdict of the part in the
+// latest detection result, PartNotCompared when that result does not list the
+// part, or empty when the BOM has not been inspected.
+type PartUsage struct {
+	BomCode          string           `json:"bomCode"`
+	PartName         string           `json:"partName"`
+	Quantity         int              `json:"quantity"`
+	Via              []string         `json:"via"`
+	InspectionStatus string           `json:"inspectionStatus"`
+	PartStatus       string           `json:"partStatus,omitempty"`
+	LastInspectedAt  *time.Time       `json:"lastInspectedAt"`
+	OpenActionItems  []ActionableItem `json:"openActionItems"`
+}
+
+type WhereUsed struct {
+	PartReference string      `json:"partReference"`
+	CatalogName   string      `json:"catalogName,omitempty"`
+	Usages        []PartUsage `json:"usages"`
+}
+
+// CatalogMatch is the catalog part an import row was matched with. Resolved
+// means the row was linked to it; otherwise it is only a suggestion.
+type CatalogMatch struct {
+	PartNumber string  `json:"partNumber"`
+	Name       string  `json:"name"`
+	Score      float64 `json:"score"`
+	Resolved   bool    `json:"resolved"`
+}
+
+// PartClassAlias links a BOM part, by reference or name, to a detector class name.
+// LooseMatch ignores case and whitespace when matching part and class names.
+type PartClassAlias struct {
+	ID            int       `json:"id"`
+	PartReference string    `json:"partReference"`
+	PartName      string    `json:"partName"`
+	ClassName     string    `json:"className"`
+	LooseMatch    bool      `json:"looseMatch"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+type DetectionJob struct {
+	ID         string            `json:"id"`
+	BomCode    string            `json:"bomCode"`
+	Status     string            `json:"status"`
+	Attempts   int               `json:"attempts"`
+	Error      string            `json:"error,omitempty"`
+	RunID      *int              `json:"runId,omitempty"`
+	Result     *ComparisonResult `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
 }
 
 type ActionableItem struct {
@@ -65,6 +616,15 @@ type FinalizeRequest struct {
 	Items []ActionableItem `json:"items"`
 }
 
+type FinalizeInspectionRequest struct {
+	FinalizedBy string `json:"finalizedBy"`
+}
+
+type ReopenInspectionRequest struct {
+	Supervisor string `json:"supervisor" binding:"required"`
+	Reason     string `json:"reason" binding:"required"`
+}
+
 type UpdateStatusRequest struct {
 	Status string `json:"status" binding:"required"`
 }
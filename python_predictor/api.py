@@ -1,6 +1,7 @@
 import io
 import os
 import base64
+from collections import OrderedDict
 import cv2
 import numpy as np
 import tempfile
@@ -21,22 +22,36 @@ except Exception as e:
     print(f"❌ Error saat memuat model default: {e}")
     default_model = None
 
+# Model custom yang dikirim bersama model_key disimpan di sini, sehingga gambar
+# berikutnya cukup mengirim model_key tanpa mengunggah ulang file .pt.
+MAX_CACHED_MODELS = 3
+cached_models = OrderedDict()
+
 @app.route('/predict', methods=['POST'])
 def predict():
     active_model = default_model
     temp_model_file = None
+    model_key = request.form.get('model_key', '')
 
-    if 'model' in request.files:
+    if 'model' in request.files and request.files['model'].filename != '':
         model_file = request.files['model']
-        if model_file.filename != '':
-            try:
-                with tempfile.NamedTemporaryFile(suffix=".pt", delete=False) as temp:
-                    model_file.save(temp.name)
-                    temp_model_file = temp.name
-                print(f"Menggunakan model custom: {model_file.filename}")
-                active_model = YOLO(temp_model_file)
-            except Exception as e:
-                return jsonify({'error': f'Gagal memuat model custom: {str(e)}'}), 500
+        try:
+            with tempfile.NamedTemporaryFile(suffix=".pt", delete=False) as temp:
+                model_file.save(temp.name)
+                temp_model_file = temp.name
+            print(f"Menggunakan model custom: {model_file.filename}")
+            active_model = YOLO(temp_model_file)
+        except Exception as e:
+            return jsonify({'error': f'Gagal memuat model custom: {str(e)}'}), 500
+        if model_key:
+            cached_models[model_key] = active_model
+            while len(cached_models) > MAX_CACHED_MODELS:
+                cached_models.popitem(last=False)
+    elif model_key:
+        if model_key not in cached_models:
+            return jsonify({'error': f'Model {model_key} belum dikirim'}), 409
+        cached_models.move_to_end(model_key)
+        active_model = cached_models[model_key]
 
     if active_model is None:
         return jsonify({'error': 'Model tidak berhasil dimuat'}), 500
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LocalStore keeps objects as files below a root directory.
+type LocalStore struct {
+	Dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+// path maps a key to a file, refusing keys that would escape the root directory.
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || slices.Contains(strings.Split(key, "/"), "..") {
+		return "", fmt.Errorf("key tidak valid: %q", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial image.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return f, contentTypeFor(key, head[:n]), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store keeps objects in a bucket of any S3-compatible service (AWS S3, MinIO, ...).
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(ctx context.Context, cfg Config) (*S3Store, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("gagal memeriksa bucket %s: %w", cfg.S3Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, fmt.Errorf("gagal membuat bucket %s: %w", cfg.S3Bucket, err)
+		}
+	}
+	return &S3Store{client: client, bucket: cfg.S3Bucket}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if contentType == "" {
+		contentType = contentTypeFor(key, data)
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	return obj, info.ContentType, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrNotFound is returned by Get when no object exists under the key.
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps binary objects such as inspection images under slash-separated keys.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config selects and configures a Store backend.
+type Config struct {
+	Backend     string // "local" or "s3"
+	LocalDir    string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+func New(ctx context.Context, cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalStore(cfg.LocalDir)
+	case "s3":
+		return NewS3Store(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("storage backend %q tidak dikenal (pilih local atau s3)", cfg.Backend)
+	}
+}
+
+// contentTypeFor guesses a content type from the key extension, then from the data.
+func contentTypeFor(key string, data []byte) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(data)
+}
+
+// KeySegment makes s safe to use as one segment of a key: every character outside
+// [A-Za-z0-9._-] becomes "_", so keys need no escaping in URLs or file names.
+func KeySegment(s string) string {
+	segment := strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (r == '.' || r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, s)
+	if strings.Trim(segment, ".") == "" {
+		return strings.Repeat("_", max(len(segment), 1))
+	}
+	return segment
+}
+
+// ExtensionFor returns a file extension, including the dot, for an image content type.
+func ExtensionFor(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".bin"
+}
+
+// DecodeDataURL splits a base64 "data:<type>;base64,<payload>" URL into its bytes
+// and content type.
+func DecodeDataURL(dataURL string) ([]byte, string, error) {
+	header, payload, ok := strings.Cut(dataURL, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, "", errors.New("bukan data URL base64")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	contentType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// ConfigFromEnv reads the STORAGE_* and S3_* environment variables.
+func ConfigFromEnv() Config {
+	get := func(key, fallback string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fallback
+	}
+	return Config{
+		Backend:     get("STORAGE_BACKEND", "local"),
+		LocalDir:    get("STORAGE_DIR", "./data/images"),
+		S3Endpoint:  get("S3_ENDPOINT", "localhost:9000"),
+		S3Region:    get("S3_REGION", "us-east-1"),
+		S3Bucket:    get("S3_BUCKET", "yolo-images"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:    get("S3_USE_SSL", "false") == "true",
+	}
+}